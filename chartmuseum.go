@@ -2,13 +2,13 @@ package chartmuseum
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/google/go-querystring/query"
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -30,6 +31,11 @@ const (
 
 	headerRateLimit = "RateLimit-Limit"
 	headerRateReset = "RateLimit-Reset"
+
+	// Default retry policy, the wait time is used for service interruptions.
+	defaultRetryMax     = 3
+	defaultRetryWaitMin = 700 * time.Millisecond
+	defaultRetryWaitMax = 900 * time.Millisecond
 )
 
 // AuthType represents an authentication type within ChartMuseum.
@@ -39,6 +45,7 @@ type AuthType int
 const (
 	DefaultNoAuth AuthType = iota
 	BasicAuth
+	BearerAuth
 	// JobToken
 	// OAuthToken
 	//PrivateToken
@@ -56,10 +63,19 @@ type Client struct {
 	// disableRetries is used to disable the default retry logic.
 	disableRetries bool
 
+	// checkRetry replaces the default retry logic if set.
+	checkRetry retryablehttp.CheckRetry
+
 	// configureLimiterOnce is used to make sure the limiter is configured exactly
 	// once and block all other calls until the initial (one) call is done.
 	configureLimiterOnce sync.Once
 
+	// Limiter is used to limit API calls and prevent 429 responses.
+	limiter RateLimiter
+
+	// limiterFromHeader sizes the limiter using the RateLimit-Limit header.
+	limiterFromHeader bool
+
 	// Token type used to make authenticated API calls.
 	authType AuthType
 
@@ -69,6 +85,25 @@ type Client struct {
 	// Token used to make authenticated API calls.
 	token string
 
+	// Source of the bearer tokens, consulted before each request.
+	tokenSource TokenSource
+
+	// Middlewares wrapping roundTrip, which sends all requests.
+	middlewares []Middleware
+	roundTrip   RoundTripFunc
+
+	// Logger used to log the requests at debug level.
+	logger Logger
+
+	// Hooks called before each attempt to send a request, including retries.
+	attemptHooks []func(req *http.Request, attempt int)
+
+	// TLS options, applied to a copy of the transport by configureTLS.
+	tlsOptions []func(*tls.Config)
+
+	// passCredentialsAll keeps the credentials on redirects to other hosts.
+	passCredentialsAll bool
+
 	// Protects the token field from concurrent read/write accesses.
 	tokenLock sync.RWMutex
 
@@ -122,6 +157,21 @@ func NewBasicAuthClient(username, password string, options ...ClientOptionFunc)
 	return client, nil
 }
 
+// NewTokenClient returns a new ChartMuseum API client. To use API methods
+// which require authentication, provide a valid bearer token (JWT). The token
+// can be rotated later on with SetToken.
+func NewTokenClient(token string, options ...ClientOptionFunc) (*Client, error) {
+	client, err := newClient(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	client.authType = BearerAuth
+	client.token = token
+
+	return client, nil
+}
+
 func newClient(options ...ClientOptionFunc) (*Client, error) {
 	c := &Client{UserAgent: userAgent}
 
@@ -130,8 +180,11 @@ func newClient(options ...ClientOptionFunc) (*Client, error) {
 		CheckRetry:   c.retryHTTPCheck,
 		ErrorHandler: retryablehttp.PassthroughErrorHandler,
 		HTTPClient:   cleanhttp.DefaultPooledClient(),
-		RetryMax:     3,
+		RetryWaitMin: defaultRetryWaitMin,
+		RetryWaitMax: defaultRetryWaitMax,
+		RetryMax:     defaultRetryMax,
 	}
+	c.client.RequestLogHook = c.requestLogHook
 
 	// Set the default base URL.
 	c.setBaseURL(defaultBaseURL)
@@ -146,6 +199,13 @@ func newClient(options ...ClientOptionFunc) (*Client, error) {
 		}
 	}
 
+	if err := c.configureTLS(); err != nil {
+		return nil, err
+	}
+	c.configureRedirects()
+
+	c.buildRoundTrip()
+
 	//c.Repositories = &RepositoriesService{client: c}
 	c.Charts = &ChartService{client: c}
 	c.Info = &InfoService{client: c}
@@ -153,20 +213,59 @@ func newClient(options ...ClientOptionFunc) (*Client, error) {
 }
 
 // retryHTTPCheck provides a callback for Client.CheckRetry which
-// will retry both rate limit (429) and Server (>= 500) errors.
+// will retry both rate limit (429) and Server (>= 500) errors. Requests
+// with an idempotent method are retried on a reset connection as well.
+// If a custom CheckRetry was configured it is used instead.
 func (c *Client) retryHTTPCheck(ctx context.Context, resp *http.Response, err error) (bool, error) {
 	if ctx.Err() != nil {
 		return false, ctx.Err()
 	}
+	if c.disableRetries {
+		return false, err
+	}
+	if c.checkRetry != nil {
+		return c.checkRetry(ctx, resp, err)
+	}
 	if err != nil {
+		if isIdempotent(ctx) && isConnectionReset(err) {
+			return true, nil
+		}
 		return false, err
 	}
-	if !c.disableRetries && (resp.StatusCode == 429 || resp.StatusCode >= 500) {
+	if resp.StatusCode == 429 || resp.StatusCode >= 500 {
 		return true, nil
 	}
 	return false, nil
 }
 
+// requestMethodKey is the context key of the method of the request, so
+// that retryHTTPCheck can tell which requests are safe to replay.
+type requestMethodKey struct{}
+
+// isIdempotent reports whether the request of ctx may be replayed after the
+// connection broke down while it was in flight.
+func isIdempotent(ctx context.Context) bool {
+	switch ctx.Value(requestMethodKey{}) {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isConnectionReset reports whether err was caused by the server closing the
+// connection.
+func isConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// requestLogHook provides a callback for Client.RequestLogHook which calls
+// the attempt hooks before every attempt, the first one being attempt 0.
+func (c *Client) requestLogHook(_ retryablehttp.Logger, req *http.Request, attempt int) {
+	for _, hook := range c.attemptHooks {
+		hook(req, attempt)
+	}
+}
+
 // retryHTTPBackoff provides a generic callback for Client.Backoff which
 // will pass through all calls based on the status code of the response.
 func (c *Client) retryHTTPBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
@@ -175,10 +274,6 @@ func (c *Client) retryHTTPBackoff(min, max time.Duration, attemptNum int, resp *
 		return rateLimitBackoff(min, max, attemptNum, resp)
 	}
 
-	// Set custom duration's when we experience a service interruption.
-	min = 700 * time.Millisecond
-	max = 900 * time.Millisecond
-
 	return retryablehttp.LinearJitterBackoff(min, max, attemptNum, resp)
 }
 
@@ -216,6 +311,37 @@ func (c *Client) BaseURL() *url.URL {
 	return &u
 }
 
+// Token returns the token currently used for bearer authentication.
+func (c *Client) Token() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
+	return c.token
+}
+
+// SetToken replaces the token used for bearer authentication. It is safe to
+// call SetToken while requests are in flight, the new token is used for all
+// requests sent afterwards.
+func (c *Client) SetToken(token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	c.token = token
+}
+
+// bearerToken returns the token for the next request. If a TokenSource is
+// configured it is consulted and the returned token is remembered.
+func (c *Client) bearerToken(ctx context.Context) (string, error) {
+	if c.tokenSource == nil {
+		return c.Token(), nil
+	}
+
+	token, err := c.tokenSource.Token(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get bearer token")
+	}
+	c.SetToken(token)
+	return token, nil
+}
+
 // setBaseURL sets the base URL for API requests to a custom endpoint.
 func (c *Client) setBaseURL(urlStr string) error {
 	// Make sure the given URL end with a slash
@@ -258,9 +384,6 @@ func (c *Client) NewRequest(method, path string, opt interface{}, options []Requ
 	}
 	var body interface{}
 
-	if _, ok := opt.(io.Reader); ok {
-
-	}
 	switch opt.(type) {
 	case *os.File:
 		// 上传 chart 包时，如果前面没有设置 RequestOptionFunc，添加 WithUpload
@@ -279,6 +402,11 @@ func (c *Client) NewRequest(method, path string, opt interface{}, options []Requ
 			options = append(options, WithUpload(mediaType, stat.Size()))
 		}
 		body = opt
+	case io.Reader:
+		// 内存中构造的请求体（例如 chart 包或 multipart 表单），Content-Type 默认为
+		// application/octet-stream，可以通过 WithUpload 覆盖
+		reqHeaders.Set("Content-Type", "application/octet-stream")
+		body = opt
 	case struct{}:
 		if method == http.MethodPost || method == http.MethodPut {
 			// 其他场景 json 数据
@@ -337,22 +465,26 @@ func newResponse(r *http.Response) *Response {
 // interface, the raw response body will be written to v, without attempting to
 // first decode it.
 func (c *Client) Do(req *retryablehttp.Request, v interface{}) (*Response, error) {
+	// Remember the method, so retryHTTPCheck knows if the request is idempotent.
+	req = req.WithContext(context.WithValue(req.Context(), requestMethodKey{}, req.Method))
+
 	// If not yet configured, try to configure the rate limiter. Fail
 	// silently as the limiter will be disabled in case of an error.
-	//c.configureLimiterOnce.Do(func() { c.configureLimiter(req.Context()) })
+	c.configureLimiterOnce.Do(c.configureLimiter)
 
 	// Wait will block until the limiter can obtain a new token.
-	//err := c.limiter.Wait(req.Context())
-	//if err != nil {
-	//	return nil, err
-	//}
+	err := c.limiter.Wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
 
 	// Set the correct authentication header. If using basic auth, then check
 	// if we already have a token and if not first authenticate and get one.
 	//var basicAuthToken string
+	var bearerToken string
 	switch c.authType {
 	case DefaultNoAuth:
-		logrus.Debug("The authentication mode does not exist")
+		// Requests are sent without credentials.
 	case BasicAuth:
 		req.SetBasicAuth(c.username, c.password)
 		/*c.tokenLock.RLock()
@@ -366,6 +498,15 @@ func (c *Client) Do(req *retryablehttp.Request, v interface{}) (*Response, error
 			}
 		}
 		req.Header.Set("Authorization", "Bearer "+basicAuthToken)*/
+	case BearerAuth:
+		if values := req.Header.Values("Authorization"); len(values) == 0 {
+			token, err := c.bearerToken(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			bearerToken = token
+			req.Header.Set("Authorization", "Bearer "+bearerToken)
+		}
 	/*case JobToken:
 		if values := req.Header.Values("JOB-TOKEN"); len(values) == 0 {
 			req.Header.Set("JOB-TOKEN", c.token)
@@ -382,20 +523,27 @@ func (c *Client) Do(req *retryablehttp.Request, v interface{}) (*Response, error
 		return nil, errors.New("The authentication mode does not exist")
 	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.roundTrip(req)
 	if err != nil {
 		return nil, err
 	}
 
-	/*if resp.StatusCode == http.StatusUnauthorized && c.authType == BasicAuth {
+	if resp.StatusCode == http.StatusUnauthorized && bearerToken != "" && c.tokenSource != nil {
 		resp.Body.Close()
-		// The token most likely expired, so we need to request a new one and try again.
-		if _, err := c.requestOAuthToken(req.Context(), basicAuthToken); err != nil {
+		// The token most likely expired, so we need to request a new one and try again once.
+		c.tokenSource.Invalidate(bearerToken)
+		token, err := c.bearerToken(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err = c.roundTrip(req)
+		if err != nil {
 			return nil, err
 		}
-		return c.Do(req, v)
 	}
-	defer resp.Body.Close()*/
+	defer resp.Body.Close()
 
 	response := newResponse(resp)
 
@@ -431,6 +579,10 @@ type ErrorResponse struct {
 	Body     []byte
 	Response *http.Response
 	Message  string
+
+	// ErrorMessage holds the message of the {"error": "..."} body returned
+	// by ChartMuseum, if any.
+	ErrorMessage string
 }
 
 func (e *ErrorResponse) Error() string {
@@ -439,6 +591,25 @@ func (e *ErrorResponse) Error() string {
 	return fmt.Sprintf("%s %s: %d %s", e.Response.Request.Method, u, e.Response.StatusCode, e.Message)
 }
 
+// Is reports whether the error matches one of the sentinel errors based on
+// the status code of the response, so errors.Is(err, ErrNotFound) can be used.
+func (e *ErrorResponse) Is(target error) bool {
+	if e.Response == nil {
+		return false
+	}
+	switch target {
+	case ErrNotFound:
+		return e.Response.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.Response.StatusCode == http.StatusConflict
+	case ErrUnauthorized:
+		return e.Response.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.Response.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
 // CheckResponse checks the API response for errors, and returns them if present.
 func CheckResponse(r *http.Response) error {
 	switch r.StatusCode {
@@ -456,6 +627,9 @@ func CheckResponse(r *http.Response) error {
 			errorResponse.Message = "failed to parse unknown error format"
 		} else {
 			errorResponse.Message = parseError(raw)
+			if m, ok := raw.(map[string]interface{}); ok {
+				errorResponse.ErrorMessage, _ = m["error"].(string)
+			}
 		}
 	}
 
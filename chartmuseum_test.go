@@ -0,0 +1,198 @@
+package chartmuseum
+
+import (
+	"context"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_BearerAuth(t *testing.T) {
+	convey.Convey("使用 bearer token 认证并轮换 token", t, func() {
+		var lock sync.Mutex
+		var authorization []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			authorization = append(authorization, r.Header.Get("Authorization"))
+			lock.Unlock()
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+
+		client, err := NewTokenClient("token-1", WithBaseURL(ts.URL))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(client.Token(), convey.ShouldEqual, "token-1")
+
+		_, err = client.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.SetToken("token-2")
+				client.Info.Health()
+			}()
+		}
+		wg.Wait()
+
+		convey.So(client.Token(), convey.ShouldEqual, "token-2")
+		convey.So(authorization[0], convey.ShouldEqual, "Bearer token-1")
+		for _, v := range authorization[1:] {
+			convey.So(v, convey.ShouldEqual, "Bearer token-2")
+		}
+	})
+}
+
+func TestClient_Retry(t *testing.T) {
+	convey.Convey("重试策略", t, func() {
+		var lock sync.Mutex
+		attempts := 0
+		failures := 0
+		reset := false
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			attempts++
+			if attempts > failures {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"healthy":true,"saved":true}`))
+				return
+			}
+			if reset {
+				// Drop the connection without answering.
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+		// The counters are shared with the handler, so they are only accessed
+		// with the lock held.
+		setFailures := func(n int, connReset bool) {
+			lock.Lock()
+			defer lock.Unlock()
+			attempts, failures, reset = 0, n, connReset
+		}
+		getAttempts := func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return attempts
+		}
+		fastRetry := WithCustomRetryWaitMinMax(time.Millisecond, 2*time.Millisecond)
+
+		convey.Convey("默认重试 5xx", func() {
+			setFailures(2, false)
+			client, _ := NewClient(WithBaseURL(ts.URL), fastRetry)
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(getAttempts(), convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("自定义最大重试次数", func() {
+			setFailures(5, false)
+			client, _ := NewClient(WithBaseURL(ts.URL), fastRetry, WithCustomRetryMax(1))
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(getAttempts(), convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("关闭重试", func() {
+			setFailures(1, false)
+			client, _ := NewClient(WithBaseURL(ts.URL), fastRetry, WithoutRetries())
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(getAttempts(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("自定义重试条件", func() {
+			setFailures(1, false)
+			client, _ := NewClient(WithBaseURL(ts.URL), fastRetry, WithCustomRetry(func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+				return false, err
+			}))
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(getAttempts(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("连接被重置时只重试幂等请求", func() {
+			setFailures(1, true)
+			client, _ := NewClient(WithBaseURL(ts.URL), fastRetry)
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(getAttempts(), convey.ShouldEqual, 2)
+
+			setFailures(1, true)
+			_, err = client.Charts.UploadChart(testRepo, "./testdata/chart-demo-0.1.0.tgz")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(getAttempts(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("非法的重试参数", func() {
+			_, err := NewClient(WithCustomRetryWaitMinMax(time.Second, time.Millisecond))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = NewClient(WithCustomRetryMax(-1))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClient_HTTPClientAndTransport(t *testing.T) {
+	convey.Convey("使用自定义的 http.Client 和 Transport", t, func() {
+		attempts := 0
+		transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			status := http.StatusOK
+			if attempts == 1 {
+				status = http.StatusBadGateway
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(`{"healthy":true}`)),
+				Request:    req,
+			}, nil
+		})
+		fastRetry := WithCustomRetryWaitMinMax(time.Millisecond, 2*time.Millisecond)
+
+		convey.Convey("WithTransport", func() {
+			client, err := NewClient(WithBaseURL("http://chartmuseum.test"), fastRetry, WithTransport(transport))
+			convey.So(err, convey.ShouldBeNil)
+			health, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(health.Healthy, convey.ShouldBeTrue)
+			convey.So(attempts, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("WithHTTPClient", func() {
+			httpClient := &http.Client{Transport: transport}
+			client, err := NewClient(WithBaseURL("http://chartmuseum.test"), fastRetry, WithHTTPClient(httpClient))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(client.client.HTTPClient, convey.ShouldEqual, httpClient)
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(attempts, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("nil 参数", func() {
+			_, err := NewClient(WithHTTPClient(nil))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = NewClient(WithTransport(nil))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,59 @@
+package chartmuseumtest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"time"
+)
+
+// ChartArchive returns a minimal packaged chart with the given name and
+// version, which only contains its Chart.yaml.
+func ChartArchive(name, version string) ([]byte, error) {
+	chartYaml := fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\ndescription: A Helm chart for testing\n", name, version)
+
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name + "/Chart.yaml",
+		Mode:    0644,
+		Size:    int64(len(chartYaml)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write([]byte(chartYaml)); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ProvenanceFile returns a fake provenance file for the chart archive with the
+// given name and version. Its signature is not valid, but it has the layout
+// of a provenance file generated by helm.
+func ProvenanceFile(name, version string) []byte {
+	return []byte(fmt.Sprintf(`-----BEGIN PGP SIGNED MESSAGE-----
+Hash: SHA512
+
+apiVersion: v2
+name: %[1]s
+version: %[2]s
+
+...
+files:
+  %[1]s-%[2]s.tgz: sha256:0000000000000000000000000000000000000000000000000000000000000000
+-----BEGIN PGP SIGNATURE-----
+
+wsBcBAEBCgAQBQJjQAAACRAAAAAAAAAAAAAA
+-----END PGP SIGNATURE-----
+`, name, version))
+}
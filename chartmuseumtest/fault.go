@@ -0,0 +1,110 @@
+package chartmuseumtest
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Fault describes a failure injected into the responses of the server.
+type Fault struct {
+	// Match selects the requests the fault applies to. A nil Match applies
+	// it to every request.
+	Match func(*http.Request) bool
+
+	// Times is the number of matching requests the fault applies to, after
+	// which it is removed. Zero applies it to every matching request.
+	Times int
+
+	// Latency delays the response.
+	Latency time.Duration
+
+	// StatusCode, when set, fails the request with the status code instead
+	// of serving it, e.g. http.StatusServiceUnavailable or
+	// http.StatusTooManyRequests.
+	StatusCode int
+
+	// RetryAfter sets the Retry-After header of a failed request, in seconds.
+	RetryAfter int
+
+	// Header is added to the response, e.g. to send RateLimit-Limit.
+	Header http.Header
+}
+
+// InjectFault adds a fault to the server. Faults apply in the order they were
+// added, the first matching fault which fails the request wins.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// MatchPath returns a Fault.Match which selects the requests for p, e.g.
+// "/api/test/charts".
+func MatchPath(p string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return path.Clean("/"+r.URL.Path) == p
+	}
+}
+
+// MatchMethod returns a Fault.Match which selects the requests with method.
+func MatchMethod(method string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return r.Method == method
+	}
+}
+
+// applyFaults applies the matching faults to the request and reports whether
+// the response has been written.
+func (s *Server) applyFaults(w http.ResponseWriter, r *http.Request) bool {
+	var (
+		latency time.Duration
+		failed  *Fault
+	)
+	s.mu.Lock()
+	faults := s.faults[:0]
+	for _, f := range s.faults {
+		if (f.Match == nil || f.Match(r)) && (failed == nil || f.StatusCode == 0) {
+			latency += f.Latency
+			for k, v := range f.Header {
+				w.Header()[k] = append(w.Header()[k], v...)
+			}
+			if f.StatusCode != 0 {
+				failed = f
+			}
+			if f.Times > 0 {
+				f.Times--
+				if f.Times == 0 {
+					continue
+				}
+			}
+		}
+		faults = append(faults, f)
+	}
+	s.faults = faults
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if failed == nil {
+		return false
+	}
+	if failed.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(failed.RetryAfter))
+	}
+	writeError(w, failed.StatusCode, http.StatusText(failed.StatusCode))
+	return true
+}
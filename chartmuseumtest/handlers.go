@@ -0,0 +1,185 @@
+package chartmuseumtest
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// maxUploadSize limits the size of uploaded charts and provenance files.
+const maxUploadSize = 20 << 20
+
+func (s *Server) handleWelcome(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	io.WriteString(w, "<!DOCTYPE html>\n<html>\n<head><title>Welcome to ChartMuseum!</title></head>\n<body><h1>Welcome to ChartMuseum!</h1></body>\n</html>\n")
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]bool{"healthy": true})
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"version": s.version})
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request, repo string) {
+	data, err := yaml.Marshal(s.index(repo))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(data)
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request, repo, name string) {
+	data, ok := s.file(repo, name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "object not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	if strings.HasSuffix(name, ".prov") {
+		w.Header().Set("Content-Type", "application/pgp-signature")
+	}
+	w.Write(data)
+}
+
+func (s *Server) handleListCharts(w http.ResponseWriter, r *http.Request, repo string) {
+	writeJSON(w, http.StatusOK, s.charts(repo))
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request, repo, chart string) {
+	cvs := s.versions(repo, chart)
+	if len(cvs) == 0 {
+		writeError(w, http.StatusNotFound, "chart not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, cvs)
+}
+
+func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request, repo, chart, version string) {
+	cv, ok := s.chartVersion(repo, chart, version)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no chart version found for "+chart+"-"+version)
+		return
+	}
+	writeJSON(w, http.StatusOK, cv)
+}
+
+func (s *Server) handleDeleteVersion(w http.ResponseWriter, r *http.Request, repo, chart, version string) {
+	if !s.deleteVersion(repo, chart, version) {
+		writeError(w, http.StatusNotFound, "remove "+chartFileName(chart, version)+": no such file or directory")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"deleted": true})
+}
+
+// handleUploadChart accepts either the raw chart archive as the request body
+// or a multipart form with a "chart" and an optional "prov" field.
+func (s *Server) handleUploadChart(w http.ResponseWriter, r *http.Request, repo string) {
+	// ChartMuseum only checks whether the force parameter is present.
+	_, force := r.URL.Query()["force"]
+	overwrite := s.overwrite || (!s.noForce && force)
+
+	chart, prov, err := readUpload(r, "chart")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if chart == nil {
+		writeError(w, http.StatusBadRequest, "no package or provenance file")
+		return
+	}
+	if err := s.putChart(repo, chart, overwrite); err != nil {
+		status := http.StatusBadRequest
+		if err == ErrExists {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	if prov != nil {
+		if err := s.putProvenance(repo, prov); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusCreated, map[string]bool{"saved": true})
+}
+
+// handleUploadProvenance accepts either the raw provenance file as the
+// request body or a multipart form with a "prov" field.
+func (s *Server) handleUploadProvenance(w http.ResponseWriter, r *http.Request, repo string) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	prov, _, err := readUpload(r, "prov")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if prov == nil {
+		writeError(w, http.StatusBadRequest, "no package or provenance file")
+		return
+	}
+	if err := s.putProvenance(repo, prov); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]bool{"saved": true})
+}
+
+// readUpload returns the uploaded file in field and, for multipart uploads,
+// the provenance file in the "prov" field.
+func readUpload(r *http.Request, field string) ([]byte, []byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxUploadSize))
+		if err != nil || len(data) == 0 {
+			return nil, nil, err
+		}
+		return data, nil, nil
+	}
+
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid multipart form")
+	}
+	data, err := formFile(r, field)
+	if err != nil {
+		return nil, nil, err
+	}
+	if field == "prov" {
+		return data, nil, nil
+	}
+	prov, err := formFile(r, "prov")
+	return data, prov, err
+}
+
+func formFile(r *http.Request, field string) ([]byte, error) {
+	file, _, err := r.FormFile(field)
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
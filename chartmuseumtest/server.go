@@ -0,0 +1,196 @@
+// Package chartmuseumtest provides an in-memory fake ChartMuseum server for
+// tests.
+//
+// The fake implements the ChartMuseum API on top of httptest: uploading,
+// listing, downloading and deleting charts and provenance files, the
+// index.yaml of every repository and the /health and /info endpoints.
+// Repositories are multi-tenant and created on first use, at any depth, so
+// "org/team" is as valid a repo as "test". Basic or bearer auth and fault
+// injection (latency, 5xx, 429) can be enabled per server.
+//
+//	srv := chartmuseumtest.NewServer(chartmuseumtest.WithBasicAuth("admin", "password"))
+//	defer srv.Close()
+//	srv.AddChartVersion("test", "mysql", "9.3.4")
+//	client, _ := chartmuseum.NewBasicAuthClient("admin", "password", chartmuseum.WithBaseURL(srv.URL))
+package chartmuseumtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultVersion is the ChartMuseum version reported by /info.
+const DefaultVersion = "v0.15.0"
+
+// Server is a fake ChartMuseum server. The embedded httptest.Server provides
+// the URL to point the client at and Close to shut the server down.
+type Server struct {
+	*httptest.Server
+
+	username  string
+	password  string
+	token     string
+	anonGet   bool
+	version   string
+	noForce   bool
+	overwrite bool
+
+	mu     sync.Mutex
+	repos  map[string]*repository
+	faults []*Fault
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithBasicAuth requires HTTP basic auth with the given credentials.
+func WithBasicAuth(username, password string) Option {
+	return func(s *Server) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// WithBearerToken requires an "Authorization: Bearer <token>" header. When
+// combined with WithBasicAuth either of them is accepted.
+func WithBearerToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithAnonymousGet allows GET and HEAD requests without credentials, like
+// ChartMuseum's --auth-anonymous-get.
+func WithAnonymousGet() Option {
+	return func(s *Server) {
+		s.anonGet = true
+	}
+}
+
+// WithAllowOverwrite allows uploading an existing chart version without the
+// force parameter, like ChartMuseum's --allow-overwrite.
+func WithAllowOverwrite() Option {
+	return func(s *Server) {
+		s.overwrite = true
+	}
+}
+
+// WithDisableForceOverwrite ignores the force parameter on uploads, like
+// ChartMuseum's --disable-force-overwrite.
+func WithDisableForceOverwrite() Option {
+	return func(s *Server) {
+		s.noForce = true
+	}
+}
+
+// WithVersion sets the version reported by /info.
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// NewServer starts and returns a new fake ChartMuseum server. The caller
+// should call Close when finished, to shut it down.
+func NewServer(options ...Option) *Server {
+	s := &Server{
+		version: DefaultVersion,
+		repos:   make(map[string]*repository),
+	}
+	for _, fn := range options {
+		fn(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.applyFaults(w, r) {
+		return
+	}
+
+	// The client joins the base URL and the path with a slash, e.g. "//health".
+	p := path.Clean("/" + r.URL.Path)
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	last := segments[len(segments)-1]
+
+	switch {
+	case p == "/health":
+		// ChartMuseum never requires auth for its health check.
+		s.handleHealth(w, r)
+		return
+	case !s.authorized(r):
+		w.Header().Set("WWW-Authenticate", `Basic realm="ChartMuseum"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	switch {
+	case p == "/":
+		s.handleWelcome(w, r)
+	case p == "/info":
+		s.handleInfo(w, r)
+	case segments[0] == "api" && last == "prov":
+		s.handleUploadProvenance(w, r, join(segments[1:len(segments)-1]))
+	case segments[0] == "api":
+		s.handleAPI(w, r, segments[1:])
+	case last == "index.yaml":
+		s.handleIndex(w, r, join(segments[:len(segments)-1]))
+	case len(segments) >= 2 && segments[len(segments)-2] == "charts":
+		s.handleDownload(w, r, join(segments[:len(segments)-2]), last)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleAPI routes the requests below /api/<repo>/charts.
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request, segments []string) {
+	i := len(segments) - 1
+	for ; i >= 0 && len(segments)-i <= 3; i-- {
+		if segments[i] == "charts" {
+			break
+		}
+	}
+	if i < 0 || len(segments)-i > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	repo, rest := join(segments[:i]), segments[i+1:]
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+		s.handleListCharts(w, r, repo)
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		s.handleUploadChart(w, r, repo)
+	case len(rest) == 1 && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		s.handleListVersions(w, r, repo, rest[0])
+	case len(rest) == 2 && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		s.handleGetVersion(w, r, repo, rest[0], rest[1])
+	case len(rest) == 2 && r.Method == http.MethodDelete:
+		s.handleDeleteVersion(w, r, repo, rest[0], rest[1])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.username == "" && s.token == "" {
+		return true
+	}
+	if s.anonGet && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		return true
+	}
+	if s.username != "" {
+		if username, password, ok := r.BasicAuth(); ok && username == s.username && password == s.password {
+			return true
+		}
+	}
+	return s.token != "" && r.Header.Get("Authorization") == "Bearer "+s.token
+}
+
+func join(segments []string) string {
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,179 @@
+package chartmuseumtest_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yidaqiang/go-chartmuseum"
+	"github.com/yidaqiang/go-chartmuseum/chartmuseumtest"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServer_Charts(t *testing.T) {
+	convey.Convey("上传、查询、下载和删除 chart", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		client, _ := chartmuseum.NewClient(chartmuseum.WithBaseURL(srv.URL))
+
+		dir, err := ioutil.TempDir("", "chartmuseumtest")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		archive, err := chartmuseumtest.ChartArchive("mysql", "9.3.4")
+		convey.So(err, convey.ShouldBeNil)
+		chartPath := filepath.Join(dir, "mysql-9.3.4.tgz")
+		provPath := chartPath + ".prov"
+		convey.So(ioutil.WriteFile(chartPath, archive, 0644), convey.ShouldBeNil)
+		convey.So(ioutil.WriteFile(provPath, chartmuseumtest.ProvenanceFile("mysql", "9.3.4"), 0644), convey.ShouldBeNil)
+
+		resp, err := client.Charts.UploadChartWithProvenance("org/team", chartPath, provPath)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		convey.So(srv.AddChartVersion("org/team", "mysql", "8.8.19"), convey.ShouldBeNil)
+
+		_, err = client.Charts.UploadChart("org/team", chartPath)
+		convey.So(chartmuseum.IsConflict(err), convey.ShouldBeTrue)
+		_, err = client.Charts.UploadChart("org/team", chartPath, chartmuseum.WithForceOverwrite())
+		convey.So(err, convey.ShouldBeNil)
+
+		charts, _, err := client.Charts.ListCharts("org/team")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len((*charts)["mysql"]), convey.ShouldEqual, 2)
+		convey.So((*charts)["mysql"][0].Version, convey.ShouldEqual, "9.3.4")
+
+		// 多租户：其他 repo 中没有该 chart
+		exist, _, err := client.Charts.IsExist("test", chartmuseum.NewChartOption("mysql"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(exist, convey.ShouldBeFalse)
+
+		cv, _, err := client.Charts.GetVersion("org/team", chartmuseum.NewChartVersionOption("mysql", "latest"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(cv.Version, convey.ShouldEqual, "9.3.4")
+
+		index, _, err := client.Charts.GetIndex("org/team")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(index.Entries["mysql"]), convey.ShouldEqual, 2)
+
+		_, err = client.Charts.DownloadVerifiedChart("org/team", dir, chartmuseum.NewChartVersionOption("mysql", "8.8.19"), "")
+		convey.So(err, convey.ShouldBeNil)
+		_, err = client.Charts.DownloadProvenance("org/team", dir, chartmuseum.NewChartVersionOption("mysql", "9.3.4"))
+		convey.So(err, convey.ShouldBeNil)
+
+		_, err = client.Charts.DeleteChart("org/team", chartmuseum.NewChartVersionOption("mysql", "9.3.4"))
+		convey.So(err, convey.ShouldBeNil)
+		_, ok := srv.Chart("org/team", "mysql", "9.3.4")
+		convey.So(ok, convey.ShouldBeFalse)
+		_, ok = srv.Provenance("org/team", "mysql", "9.3.4")
+		convey.So(ok, convey.ShouldBeFalse)
+		_, err = client.Charts.DeleteChart("org/team", chartmuseum.NewChartVersionOption("mysql", "9.3.4"))
+		convey.So(chartmuseum.IsNotFound(err), convey.ShouldBeTrue)
+	})
+}
+
+func TestServer_ForceWithoutValue(t *testing.T) {
+	convey.Convey("force 参数没有值时也覆盖上传", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		archive, err := chartmuseumtest.ChartArchive("mysql", "9.3.4")
+		convey.So(err, convey.ShouldBeNil)
+
+		for _, query := range []string{"", "?force"} {
+			resp, err := http.Post(srv.URL+"/api/test/charts"+query, "application/octet-stream", bytes.NewReader(archive))
+			convey.So(err, convey.ShouldBeNil)
+			resp.Body.Close()
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		}
+	})
+}
+
+func TestServer_Auth(t *testing.T) {
+	convey.Convey("basic 和 bearer 认证", t, func() {
+		srv := chartmuseumtest.NewServer(
+			chartmuseumtest.WithBasicAuth("admin", "password"),
+			chartmuseumtest.WithBearerToken("token"),
+			chartmuseumtest.WithVersion("v0.14.0"),
+		)
+		defer srv.Close()
+
+		anonymous, _ := chartmuseum.NewClient(chartmuseum.WithBaseURL(srv.URL))
+		health, err := anonymous.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(health.Healthy, convey.ShouldBeTrue)
+		_, err = anonymous.Info.Info()
+		convey.So(chartmuseum.IsUnauthorized(err), convey.ShouldBeTrue)
+
+		basic, _ := chartmuseum.NewBasicAuthClient("admin", "password", chartmuseum.WithBaseURL(srv.URL))
+		version, err := basic.Info.Info()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(version.Version, convey.ShouldEqual, "v0.14.0")
+
+		bearer, _ := chartmuseum.NewTokenClient("token", chartmuseum.WithBaseURL(srv.URL))
+		index, err := bearer.Info.Index()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(index, convey.ShouldContainSubstring, "Welcome to ChartMuseum!")
+
+		wrong, _ := chartmuseum.NewBasicAuthClient("admin", "wrong", chartmuseum.WithBaseURL(srv.URL))
+		_, _, err = wrong.Charts.ListCharts("test")
+		convey.So(chartmuseum.IsUnauthorized(err), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("允许匿名读取", t, func() {
+		srv := chartmuseumtest.NewServer(chartmuseumtest.WithBasicAuth("admin", "password"), chartmuseumtest.WithAnonymousGet())
+		defer srv.Close()
+		convey.So(srv.AddChartVersion("test", "redis", "17.2.0"), convey.ShouldBeNil)
+
+		anonymous, _ := chartmuseum.NewClient(chartmuseum.WithBaseURL(srv.URL))
+		_, _, err := anonymous.Charts.GetVersion("test", chartmuseum.NewChartVersionOption("redis", "17.2.0"))
+		convey.So(err, convey.ShouldBeNil)
+		_, err = anonymous.Charts.DeleteChart("test", chartmuseum.NewChartVersionOption("redis", "17.2.0"))
+		convey.So(chartmuseum.IsUnauthorized(err), convey.ShouldBeTrue)
+	})
+}
+
+func TestServer_Faults(t *testing.T) {
+	convey.Convey("故障注入", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		client, _ := chartmuseum.NewClient(
+			chartmuseum.WithBaseURL(srv.URL),
+			chartmuseum.WithCustomRetryWaitMinMax(time.Millisecond, 10*time.Millisecond),
+		)
+
+		convey.Convey("5xx 后重试成功", func() {
+			srv.InjectFault(chartmuseumtest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 2})
+			health, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(health.Healthy, convey.ShouldBeTrue)
+		})
+
+		convey.Convey("429 携带 Retry-After", func() {
+			srv.InjectFault(chartmuseumtest.Fault{
+				Match:      chartmuseumtest.MatchPath("/info"),
+				StatusCode: http.StatusTooManyRequests,
+				RetryAfter: 1,
+			})
+			noRetry, _ := chartmuseum.NewClient(chartmuseum.WithBaseURL(srv.URL), chartmuseum.WithoutRetries())
+			_, err := noRetry.Info.Info()
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = noRetry.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+
+			srv.ClearFaults()
+			_, err = noRetry.Info.Info()
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("延迟超过 context 超时", func() {
+			srv.InjectFault(chartmuseumtest.Fault{Latency: time.Second, Times: 1})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := client.Info.HealthContext(ctx)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
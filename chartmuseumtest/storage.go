@@ -0,0 +1,228 @@
+package chartmuseumtest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	helmrepo "helm.sh/helm/v3/pkg/repo"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrExists is returned when uploading a chart version which already exists.
+var ErrExists = errors.New("file already exists")
+
+// repository is the in-memory storage of one tenant, keyed by file name, e.g.
+// "mysql-9.3.4.tgz" and "mysql-9.3.4.tgz.prov".
+type repository struct {
+	charts map[string]*chartFile
+	provs  map[string][]byte
+}
+
+type chartFile struct {
+	version *helmrepo.ChartVersion
+	archive []byte
+}
+
+// repo returns the repository with the given name, creating it if needed.
+// The caller must hold s.mu.
+func (s *Server) repo(name string) *repository {
+	r, ok := s.repos[name]
+	if !ok {
+		r = &repository{
+			charts: make(map[string]*chartFile),
+			provs:  make(map[string][]byte),
+		}
+		s.repos[name] = r
+	}
+	return r
+}
+
+// AddChart stores a packaged chart in repo, replacing an existing version.
+func (s *Server) AddChart(repo string, archive []byte) error {
+	return s.putChart(strings.Trim(repo, "/"), archive, true)
+}
+
+// AddChartVersion stores a minimal generated chart with the given name and
+// version in repo.
+func (s *Server) AddChartVersion(repo, name, version string) error {
+	archive, err := ChartArchive(name, version)
+	if err != nil {
+		return err
+	}
+	return s.AddChart(repo, archive)
+}
+
+// AddProvenance stores the provenance file of a chart version in repo.
+func (s *Server) AddProvenance(repo string, prov []byte) error {
+	return s.putProvenance(strings.Trim(repo, "/"), prov)
+}
+
+// Chart returns the archive of a chart version stored in repo.
+func (s *Server) Chart(repo, name, version string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cf, ok := s.repo(strings.Trim(repo, "/")).charts[chartFileName(name, version)]
+	if !ok {
+		return nil, false
+	}
+	return cf.archive, true
+}
+
+// Provenance returns the provenance file of a chart version stored in repo.
+func (s *Server) Provenance(repo, name, version string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prov, ok := s.repo(strings.Trim(repo, "/")).provs[chartFileName(name, version)+".prov"]
+	return prov, ok
+}
+
+func (s *Server) putChart(repo string, archive []byte, overwrite bool) error {
+	ch, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return errors.Wrap(err, "invalid chart archive")
+	}
+	sum := sha256.Sum256(archive)
+	name := chartFileName(ch.Metadata.Name, ch.Metadata.Version)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(repo)
+	if _, ok := r.charts[name]; ok && !overwrite {
+		return ErrExists
+	}
+	r.charts[name] = &chartFile{
+		version: &helmrepo.ChartVersion{
+			Metadata: ch.Metadata,
+			URLs:     []string{"charts/" + name},
+			Created:  time.Now(),
+			Digest:   hex.EncodeToString(sum[:]),
+		},
+		archive: archive,
+	}
+	return nil
+}
+
+func (s *Server) putProvenance(repo string, prov []byte) error {
+	name, err := provenanceChartFile(prov)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(repo).provs[name+".prov"] = prov
+	return nil
+}
+
+// versions returns the versions of a chart in repo, newest first.
+func (s *Server) versions(repo, chart string) helmrepo.ChartVersions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cvs helmrepo.ChartVersions
+	for _, cf := range s.repo(repo).charts {
+		if cf.version.Name == chart {
+			cvs = append(cvs, cf.version)
+		}
+	}
+	sort.Sort(sort.Reverse(cvs))
+	return cvs
+}
+
+// charts returns the versions of every chart in repo, newest first.
+func (s *Server) charts(repo string) map[string]helmrepo.ChartVersions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	charts := make(map[string]helmrepo.ChartVersions)
+	for _, cf := range s.repo(repo).charts {
+		charts[cf.version.Name] = append(charts[cf.version.Name], cf.version)
+	}
+	for _, cvs := range charts {
+		sort.Sort(sort.Reverse(cvs))
+	}
+	return charts
+}
+
+// chartVersion returns a chart version in repo. The version "latest" resolves to
+// the newest version, as in ChartMuseum.
+func (s *Server) chartVersion(repo, chart, version string) (*helmrepo.ChartVersion, bool) {
+	if version == "latest" {
+		cvs := s.versions(repo, chart)
+		if len(cvs) == 0 {
+			return nil, false
+		}
+		return cvs[0], true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cf, ok := s.repo(repo).charts[chartFileName(chart, version)]
+	if !ok {
+		return nil, false
+	}
+	return cf.version, true
+}
+
+func (s *Server) deleteVersion(repo, chart, version string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(repo)
+	name := chartFileName(chart, version)
+	if _, ok := r.charts[name]; !ok {
+		return false
+	}
+	delete(r.charts, name)
+	delete(r.provs, name+".prov")
+	return true
+}
+
+// file returns a stored chart archive or provenance file by its file name.
+func (s *Server) file(repo, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(repo)
+	if cf, ok := r.charts[name]; ok {
+		return cf.archive, true
+	}
+	prov, ok := r.provs[name]
+	return prov, ok
+}
+
+// index builds the index.yaml of repo.
+func (s *Server) index(repo string) *helmrepo.IndexFile {
+	index := helmrepo.NewIndexFile()
+	for name, cvs := range s.charts(repo) {
+		index.Entries[name] = cvs
+	}
+	index.SortEntries()
+	return index
+}
+
+func chartFileName(name, version string) string {
+	return fmt.Sprintf("%s-%s.tgz", name, version)
+}
+
+// provenanceChartFile returns the chart archive a provenance file belongs to,
+// read from the "files" section of the signed message.
+func provenanceChartFile(prov []byte) (string, error) {
+	inFiles := false
+	scanner := bufio.NewScanner(bytes.NewReader(prov))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "files:":
+			inFiles = true
+		case inFiles && strings.HasPrefix(line, " "):
+			name := strings.TrimSpace(strings.SplitN(line, ":", 2)[0])
+			if strings.HasSuffix(name, ".tgz") {
+				return name, nil
+			}
+		default:
+			inFiles = false
+		}
+	}
+	return "", errors.New("invalid provenance file: no chart archive found")
+}
@@ -1,14 +1,23 @@
 package chartmuseum
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 	helmrepo "helm.sh/helm/v3/pkg/repo"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
 )
 
 const (
@@ -16,6 +25,9 @@ const (
 	chartUrlTpl        = "api/%s/charts/%s"
 	chartVersionUrlTpl = "api/%s/charts/%s/%s"
 	downloadUrlTpl     = "%s/charts/%s-%s.tgz"
+	indexUrlTpl        = "%s/index.yaml"
+	provUrlTpl         = "api/%s/prov"
+	provDownloadUrlTpl = "%s/charts/%s-%s.tgz.prov"
 )
 
 type ChartService struct {
@@ -49,6 +61,7 @@ func (c *ChartService) ListCharts(repo string, options ...RequestOptionFunc) (*m
 	if err != nil {
 		return nil, nil, err
 	}
+	options = appendOptions(options, withOperation("ListCharts", repoUrl, "", ""))
 
 	u := fmt.Sprintf(repoUrlTpl, repoUrl)
 
@@ -70,6 +83,7 @@ func (c *ChartService) ListVersions(repo string, chartOptions ChartOption, optio
 	if err != nil {
 		return nil, nil, err
 	}
+	options = appendOptions(options, withOperation("ListVersions", repoUrl, *chartOptions.Name, ""))
 
 	u := fmt.Sprintf(chartUrlTpl, repoUrl, *chartOptions.Name)
 
@@ -90,6 +104,7 @@ func (c *ChartService) GetVersion(repo string, chartVersionOptions ChartVersionO
 	if err != nil {
 		return nil, nil, err
 	}
+	options = appendOptions(options, withOperation("GetVersion", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
 
 	u := fmt.Sprintf(chartVersionUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
 
@@ -106,14 +121,35 @@ func (c *ChartService) GetVersion(repo string, chartVersionOptions ChartVersionO
 
 	return &cv, resp, err
 }
+
+// IsExist checks whether any version of the chart exists. A missing chart is
+// reported as (false, resp, nil), an error is only returned on failure.
 func (c *ChartService) IsExist(repo string, chartOptions ChartOption, options ...RequestOptionFunc) (bool, *Response, error) {
 	repoUrl, err := parseRepoUrl(repo)
 	if err != nil {
 		return false, nil, err
 	}
+	options = appendOptions(options, withOperation("IsExist", repoUrl, *chartOptions.Name, ""))
 
 	u := fmt.Sprintf(chartUrlTpl, repoUrl, *chartOptions.Name)
+	return c.exists(u, options)
+}
+
+// IsExistVersion checks whether the chart version exists. A missing version
+// is reported as (false, resp, nil), an error is only returned on failure.
+func (c *ChartService) IsExistVersion(repo string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (bool, *Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return false, nil, err
+	}
+	options = appendOptions(options, withOperation("IsExistVersion", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
+
+	u := fmt.Sprintf(chartVersionUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
+	return c.exists(u, options)
+}
 
+// exists sends a HEAD request to u and treats 404 Not Found as a valid answer.
+func (c *ChartService) exists(u string, options []RequestOptionFunc) (bool, *Response, error) {
 	req, err := c.client.NewRequest(http.MethodHead, u, nil, options)
 	if err != nil {
 		return false, nil, err
@@ -121,29 +157,42 @@ func (c *ChartService) IsExist(repo string, chartOptions ChartOption, options ..
 
 	resp, err := c.client.Do(req, nil)
 	if err != nil {
+		if IsNotFound(err) {
+			return false, resp, nil
+		}
 		return false, resp, err
 	}
 	return true, resp, err
 }
 
-func (c *ChartService) IsExistVersion(repo string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (bool, *Response, error) {
+// GetIndex downloads the index.yaml of the given repo and parses it into a
+// helm IndexFile. An empty repo fetches the index.yaml served at the root of
+// the server.
+func (c *ChartService) GetIndex(repo string, options ...RequestOptionFunc) (*helmrepo.IndexFile, *Response, error) {
 	repoUrl, err := parseRepoUrl(repo)
 	if err != nil {
-		return false, nil, err
+		return nil, nil, err
 	}
+	options = appendOptions(options, withOperation("GetIndex", repoUrl, "", ""))
 
-	u := fmt.Sprintf(chartVersionUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
+	u := strings.TrimPrefix(fmt.Sprintf(indexUrlTpl, repoUrl), "/")
 
-	req, err := c.client.NewRequest(http.MethodHead, u, nil, options)
+	req, err := c.client.NewRequest(http.MethodGet, u, nil, options)
 	if err != nil {
-		return false, nil, err
+		return nil, nil, err
 	}
 
-	resp, err := c.client.Do(req, nil)
+	data := new(bytes.Buffer)
+	resp, err := c.client.Do(req, data)
 	if err != nil {
-		return false, resp, err
+		return nil, resp, err
 	}
-	return true, resp, err
+
+	index, err := loadIndex(data.Bytes())
+	if err != nil {
+		return nil, resp, err
+	}
+	return index, resp, err
 }
 
 func (c *ChartService) DownloadChart(repo string, dest string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
@@ -151,42 +200,267 @@ func (c *ChartService) DownloadChart(repo string, dest string, chartVersionOptio
 	if err != nil {
 		return nil, err
 	}
+	options = appendOptions(options, withOperation("DownloadChart", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
+
+	u := fmt.Sprintf(downloadUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
+	return c.download(u, dest, "", options)
+}
+
+// DownloadVerifiedChart downloads a chart version like DownloadChart, but
+// hashes the archive while it is streamed and only moves it into dest if its
+// sha256 matches digest. If digest is empty, it is looked up with GetVersion.
+// A mismatch is reported as ErrDigestMismatch.
+func (c *ChartService) DownloadVerifiedChart(repo string, dest string, chartVersionOptions ChartVersionOption, digest string, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, err
+	}
+	options = appendOptions(options, withOperation("DownloadVerifiedChart", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
+
+	if digest == "" {
+		cv, resp, err := c.GetVersion(repo, chartVersionOptions, options...)
+		if err != nil {
+			return resp, err
+		}
+		if cv.Digest == "" {
+			return resp, errors.Errorf("no digest available for %s-%s", *chartVersionOptions.Name, *chartVersionOptions.Version)
+		}
+		digest = cv.Digest
+	}
+
+	u := fmt.Sprintf(downloadUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
+	return c.download(u, dest, digest, options)
+}
+
+// DownloadProvenance downloads the provenance file of a chart version into
+// the dest directory.
+func (c *ChartService) DownloadProvenance(repo string, dest string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, err
+	}
+	options = appendOptions(options, withOperation("DownloadProvenance", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
+
+	u := fmt.Sprintf(provDownloadUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
+	return c.download(u, dest, "", options)
+}
+
+// DownloadChartTo writes the archive of a chart version to w as it is
+// received, without storing it on disk.
+func (c *ChartService) DownloadChartTo(repo string, w io.Writer, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, err
+	}
+	options = appendOptions(options, withOperation("DownloadChartTo", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
 
 	u := fmt.Sprintf(downloadUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
-	data := new(bytes.Buffer)
 
 	req, err := c.client.NewRequest(http.MethodGet, u, nil, options)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.client.Do(req, data)
+	return c.client.Do(req, w)
+}
+
+// download fetches u and atomically writes it into the dest directory using
+// the last element of u as the file name. The response body is streamed into
+// the temporary file, so the archive is never buffered in memory. If digest
+// is not empty, the file is only moved into place if its sha256 matches.
+func (c *ChartService) download(u, dest, digest string, options []RequestOptionFunc) (*Response, error) {
+	req, err := c.client.NewRequest(http.MethodGet, u, nil, options)
 	if err != nil {
-		return resp, err
+		return nil, err
 	}
 
 	destFile := filepath.Join(dest, filepath.Base(u))
 
-	if err := AtomicWriteFile(destFile, data, 0644); err != nil {
-		return resp, err
-	}
+	var resp *Response
+	err = atomicWriteFileFunc(destFile, 0644, func(w io.Writer) error {
+		if digest == "" {
+			resp, err = c.client.Do(req, w)
+			return err
+		}
+
+		hash := sha256.New()
+		resp, err = c.client.Do(req, io.MultiWriter(w, hash))
+		if err != nil {
+			return err
+		}
+		return verifyDigest(digest, hash.Sum(nil))
+	})
 	return resp, err
 }
 
+// verifyDigest compares the expected hex encoded digest, optionally prefixed
+// with "sha256:", with sum.
+func verifyDigest(expected string, sum []byte) error {
+	expected = strings.ToLower(strings.TrimPrefix(expected, "sha256:"))
+	actual := hex.EncodeToString(sum)
+	if expected != actual {
+		return errors.Wrapf(ErrDigestMismatch, "expected sha256 %s, got %s", expected, actual)
+	}
+	return nil
+}
+
 func (c *ChartService) UploadChart(repo, chartFilePath string, options ...RequestOptionFunc) (*Response, error) {
 	repoUrl, err := parseRepoUrl(repo)
 	if err != nil {
 		return nil, err
 	}
+	options = appendOptions(options, withOperation("UploadChart", repoUrl, "", ""))
 
 	u := fmt.Sprintf(repoUrlTpl, repoUrl)
+	return c.upload(u, chartFilePath, options)
+}
 
-	file, err := os.Open(chartFilePath)
+// UploadChartReader uploads a chart archive read from reader, e.g. a chart
+// packaged into a buffer. size is the length of the archive, or -1 if it is
+// unknown.
+func (c *ChartService) UploadChartReader(repo string, reader io.Reader, size int64, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
 	if err != nil {
 		return nil, err
 	}
+	options = appendOptions(options, withOperation("UploadChartReader", repoUrl, "", ""))
+
+	u := fmt.Sprintf(repoUrlTpl, repoUrl)
+
+	head, body, err := sniffUpload(reader, size)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, WithUpload(http.DetectContentType(head), size))
+	req, err := c.client.NewRequest(http.MethodPost, u, body, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doUpload(req)
+}
+
+// sniffUpload returns the first 512 bytes of reader, which are used to sniff
+// the content type, and the body to send. Buffers and seekable readers are
+// passed through unwrapped, so that retryablehttp doesn't buffer them again,
+// and their length is checked against size up front. Any other reader is
+// checked against size while it is read.
+func sniffUpload(reader io.Reader, size int64) ([]byte, io.Reader, error) {
+	switch r := reader.(type) {
+	case *bytes.Buffer:
+		head := r.Bytes()
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		return head, r, checkUploadSize(int64(r.Len()), size)
+	case io.ReadSeeker:
+		// retryablehttp sends a seekable body from its start.
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		head, err := ioutil.ReadAll(io.LimitReader(r, 512))
+		if err != nil {
+			return nil, nil, err
+		}
+		length, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		return head, r, checkUploadSize(length, size)
+	default:
+		br := bufio.NewReaderSize(reader, 512)
+		head, _ := br.Peek(512)
+		if size < 0 {
+			return head, br, nil
+		}
+		return head, &sizedReader{r: br, size: size}, nil
+	}
+}
+
+func checkUploadSize(length, size int64) error {
+	if size >= 0 && length != size {
+		return errors.Errorf("chart archive is %d bytes long, not %d", length, size)
+	}
+	return nil
+}
+
+// sizedReader fails the read of an upload whose length differs from size.
+type sizedReader struct {
+	r    io.Reader
+	size int64
+	read int64
+}
+
+func (s *sizedReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.read += int64(n)
+	if s.read > s.size || (err == io.EOF && s.read != s.size) {
+		return n, checkUploadSize(s.read, s.size)
+	}
+	return n, err
+}
+
+// UploadChartWithProvenance uploads a chart together with its provenance file
+// in a single multipart request, so that the chart never becomes visible
+// without its signature.
+func (c *ChartService) UploadChartWithProvenance(repo, chartFilePath, provFilePath string, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, err
+	}
+	options = appendOptions(options, withOperation("UploadChartWithProvenance", repoUrl, "", ""))
+
+	u := fmt.Sprintf(repoUrlTpl, repoUrl)
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	if err := addFormFile(writer, "chart", chartFilePath); err != nil {
+		return nil, err
+	}
+	if err := addFormFile(writer, "prov", provFilePath); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	options = append(options, WithUpload(writer.FormDataContentType(), int64(body.Len())))
+	req, err := c.client.NewRequest(http.MethodPost, u, body, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doUpload(req)
+}
+
+// UploadProvenance uploads the provenance file of a chart which has already
+// been uploaded with UploadChart.
+func (c *ChartService) UploadProvenance(repo, provFilePath string, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, err
+	}
+	options = appendOptions(options, withOperation("UploadProvenance", repoUrl, "", ""))
+
+	u := fmt.Sprintf(provUrlTpl, repoUrl)
+	return c.upload(u, provFilePath, options)
+}
+
+// upload posts the content of the file at filePath to u.
+func (c *ChartService) upload(u, filePath string, options []RequestOptionFunc) (*Response, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
 	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
 	if stat.IsDir() {
 		return nil, errors.New("can't be update a directory")
 	}
@@ -197,12 +471,7 @@ func (c *ChartService) UploadChart(repo, chartFilePath string, options ...Reques
 		return nil, err
 	}
 
-	resp, err := c.client.Do(req, nil)
-	if err != nil {
-		return resp, err
-	}
-
-	return resp, err
+	return c.doUpload(req)
 }
 
 func (c *ChartService) DeleteChart(repo string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
@@ -210,6 +479,7 @@ func (c *ChartService) DeleteChart(repo string, chartVersionOptions ChartVersion
 	if err != nil {
 		return nil, err
 	}
+	options = appendOptions(options, withOperation("DeleteChart", repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version))
 
 	u := fmt.Sprintf(chartVersionUrlTpl, repoUrl, *chartVersionOptions.Name, *chartVersionOptions.Version)
 
@@ -232,7 +502,10 @@ func (c *ChartService) GetLatestChartVersionWithRegex(repo string, chart ChartOp
 		return version, err
 	}
 	if len(*versions) > 0 {
-		reg := regexp.MustCompile(regex)
+		reg, err := regexp.Compile(regex)
+		if err != nil {
+			return version, errors.Wrapf(err, "invalid version regex %q", regex)
+		}
 		for _, v := range *versions {
 			tagName := v.Version
 			if reg.MatchString(tagName) {
@@ -279,6 +552,59 @@ func VersionOrdinal(version string) string {
 	return string(vo)
 }
 
+// doUpload sends an upload request. When a forced overwrite was requested
+// with WithForceOverwrite and the server still answers 409 Conflict, the
+// server does not allow overwriting charts and the error says so.
+func (c *ChartService) doUpload(req *retryablehttp.Request) (*Response, error) {
+	resp, err := c.client.Do(req, nil)
+	if err != nil {
+		if _, force := req.URL.Query()["force"]; force && IsConflict(err) {
+			return resp, errors.Wrap(err, "the server refused to overwrite the existing chart version, force overwrite is disabled (--disable-force-overwrite)")
+		}
+		return resp, err
+	}
+
+	return resp, err
+}
+
+// addFormFile copies the file at filePath into a new form field of writer.
+func addFormFile(writer *multipart.Writer, field, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return errors.New("can't be update a directory")
+	}
+
+	part, err := writer.CreateFormFile(field, filepath.Base(filePath))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, file)
+	return err
+}
+
+// loadIndex parses the content of an index.yaml. Unlike helm, unknown fields
+// are ignored since ChartMuseum adds its own server information to the index.
+func loadIndex(data []byte) (*helmrepo.IndexFile, error) {
+	index := &helmrepo.IndexFile{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, errors.Wrap(err, "failed to parse index.yaml")
+	}
+	if index.APIVersion == "" {
+		return nil, helmrepo.ErrNoAPIVersion
+	}
+	index.SortEntries()
+	return index, nil
+}
+
 // detectContentType returns a valid content-type and "application/octet-stream" if error or no match
 func detectContentType(file *os.File) (string, error) {
 	// Only the first 512 bytes are used to sniff the content type.
@@ -1,19 +1,27 @@
 package chartmuseum
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"github.com/pkg/errors"
 	"github.com/smartystreets/goconvey/convey"
+	"github.com/yidaqiang/go-chartmuseum/chartmuseumtest"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 const (
-	server   = "http://localhost:8080"
 	user     = "admin"
 	password = "password"
 
-	testServer = "https://charts.ydq.io"
-	testRepo   = "test"
+	testRepo = "test"
 
 	tmpPath = "/tmp"
 )
@@ -23,10 +31,29 @@ var (
 	testClient  *Client
 )
 
-func init() {
-	localClient, _ = NewBasicAuthClient(user, password, WithBaseURL(server))
+// testCharts are the chart versions served by the fake ChartMuseum server in
+// testRepo.
+var testCharts = map[string][]string{
+	"mysql":      {"8.8.19", "9.3.4", "9.4.1"},
+	"redis":      {"15.5.0", "15.6.1", "15.7.6", "16.13.2", "17.2.0"},
+	"postgresql": {"10.16.2", "11.9.13"},
+}
+
+func TestMain(m *testing.M) {
+	srv := chartmuseumtest.NewServer(chartmuseumtest.WithBasicAuth(user, password), chartmuseumtest.WithAnonymousGet())
+	for name, versions := range testCharts {
+		for _, version := range versions {
+			if err := srv.AddChartVersion(testRepo, name, version); err != nil {
+				panic(err)
+			}
+		}
+	}
+	localClient, _ = NewBasicAuthClient(user, password, WithBaseURL(srv.URL))
+	testClient, _ = NewClient(WithBaseURL(srv.URL))
 
-	testClient, _ = NewClient(WithBaseURL(testServer))
+	code := m.Run()
+	srv.Close()
+	os.Exit(code)
 }
 
 func TestChartService_ListCharts(t *testing.T) {
@@ -126,8 +153,8 @@ func TestChartService_GetVersion(t *testing.T) {
 					fmt.Print(err)
 					continue
 				}
-			} else {
-				t.Error(err)
+				convey.So(err, convey.ShouldBeNil)
+				continue
 			}
 			convey.So((*version).Version, convey.ShouldEqual, tc.expected)
 
@@ -268,3 +295,314 @@ func TestChartService_GetLatestChartVersionWithRegex(t *testing.T) {
 		convey.So(version, convey.ShouldEqual, "15.7.6")
 	})
 }
+
+func TestChartService_GetIndex(t *testing.T) {
+	convey.Convey("获取 chart 库的 index.yaml", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		for _, repo := range []string{"", "org/team"} {
+			convey.So(srv.AddChartVersion(repo, "mysql", "8.8.19"), convey.ShouldBeNil)
+			convey.So(srv.AddChartVersion(repo, "mysql", "9.3.4"), convey.ShouldBeNil)
+		}
+
+		client, _ := NewClient(WithBaseURL(srv.URL), WithoutRetries())
+		testCases := []struct {
+			inputRepo string
+			expected  int
+		}{
+			{
+				inputRepo: "",
+				expected:  2,
+			},
+			{
+				inputRepo: "/org/team/",
+				expected:  2,
+			},
+			{
+				inputRepo: "unknown",
+				expected:  0,
+			},
+		}
+		for _, tc := range testCases {
+			indexFile, _, err := client.Charts.GetIndex(tc.inputRepo)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(indexFile.Entries["mysql"]), convey.ShouldEqual, tc.expected)
+			if tc.expected > 0 {
+				convey.So(indexFile.Entries["mysql"][0].Version, convey.ShouldEqual, "9.3.4")
+			}
+		}
+
+		srv.InjectFault(chartmuseumtest.Fault{StatusCode: http.StatusServiceUnavailable, Times: 1})
+		_, resp, err := client.Charts.GetIndex("org/team")
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusServiceUnavailable)
+	})
+}
+
+func TestChartService_Provenance(t *testing.T) {
+	convey.Convey("上传和下载 chart 的 provenance 文件", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		prov := chartmuseumtest.ProvenanceFile("chart-demo", "0.1.0")
+
+		client, _ := NewClient(WithBaseURL(srv.URL))
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		provPath := filepath.Join(dir, "upload.prov")
+		convey.So(ioutil.WriteFile(provPath, prov, 0644), convey.ShouldBeNil)
+		resp, err := client.Charts.UploadProvenance(testRepo, provPath)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		uploaded, ok := srv.Provenance(testRepo, "chart-demo", "0.1.0")
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(uploaded, convey.ShouldResemble, prov)
+
+		_, err = client.Charts.DownloadProvenance(testRepo, dir, NewChartVersionOption("chart-demo", "0.1.0"))
+		convey.So(err, convey.ShouldBeNil)
+		file, err := ioutil.ReadFile(filepath.Join(dir, "chart-demo-0.1.0.tgz.prov"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(file, convey.ShouldResemble, prov)
+
+		_, err = client.Charts.DownloadProvenance(testRepo, dir, NewChartVersionOption("chart-demo", "0.2.0"))
+		convey.So(IsNotFound(err), convey.ShouldBeTrue)
+	})
+}
+
+func TestChartService_UploadChartWithProvenance(t *testing.T) {
+	convey.Convey("同时上传 chart 和 provenance 文件", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		chartPath := "./testdata/chart-demo-0.1.0.tgz"
+		chart, _ := ioutil.ReadFile(chartPath)
+		prov := chartmuseumtest.ProvenanceFile("chart-demo", "0.1.0")
+
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+		provPath := filepath.Join(dir, "chart-demo-0.1.0.tgz.prov")
+		convey.So(ioutil.WriteFile(provPath, prov, 0644), convey.ShouldBeNil)
+
+		client, _ := NewClient(WithBaseURL(srv.URL))
+		resp, err := client.Charts.UploadChartWithProvenance(testRepo, chartPath, provPath)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		received, _ := srv.Chart(testRepo, "chart-demo", "0.1.0")
+		convey.So(received, convey.ShouldResemble, chart)
+		received, _ = srv.Provenance(testRepo, "chart-demo", "0.1.0")
+		convey.So(received, convey.ShouldResemble, prov)
+
+		_, err = client.Charts.UploadChartWithProvenance(testRepo, chartPath, filepath.Join(dir, "missing.prov"))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestChartService_Exists(t *testing.T) {
+	convey.Convey("chart 不存在时返回 false 而不是错误", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/test/charts/mysql", "/api/test/charts/mysql/9.3.4":
+				w.WriteHeader(http.StatusOK)
+			case "/api/forbidden/charts/mysql", "/api/forbidden/charts/mysql/9.3.4":
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
+		client, _ := NewClient(WithBaseURL(ts.URL))
+		testCases := []struct {
+			inputRepo               string
+			inputChartVersionOption ChartVersionOption
+			expected                bool
+			expectedErr             bool
+		}{
+			{
+				inputRepo:               testRepo,
+				inputChartVersionOption: NewChartVersionOption("mysql", "9.3.4"),
+				expected:                true,
+			},
+			{
+				inputRepo:               testRepo,
+				inputChartVersionOption: NewChartVersionOption("mysql-ha", "0.1.0"),
+				expected:                false,
+			},
+			{
+				inputRepo:               "forbidden",
+				inputChartVersionOption: NewChartVersionOption("mysql", "9.3.4"),
+				expected:                false,
+				expectedErr:             true,
+			},
+		}
+		for _, tc := range testCases {
+			result, resp, err := client.Charts.IsExist(tc.inputRepo, tc.inputChartVersionOption.ChartOption)
+			convey.So(err != nil, convey.ShouldEqual, tc.expectedErr)
+			convey.So(resp, convey.ShouldNotBeNil)
+			convey.So(result, convey.ShouldEqual, tc.expected)
+
+			result, resp, err = client.Charts.IsExistVersion(tc.inputRepo, tc.inputChartVersionOption)
+			convey.So(err != nil, convey.ShouldEqual, tc.expectedErr)
+			convey.So(resp, convey.ShouldNotBeNil)
+			convey.So(result, convey.ShouldEqual, tc.expected)
+		}
+	})
+}
+
+func TestChartService_DownloadChartStream(t *testing.T) {
+	convey.Convey("流式下载 chart", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		chart, _ := ioutil.ReadFile("./testdata/chart-demo-0.1.0.tgz")
+		convey.So(srv.AddChart(testRepo, chart), convey.ShouldBeNil)
+
+		client, _ := NewClient(WithBaseURL(srv.URL))
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		_, err = client.Charts.DownloadChart(testRepo, dir, NewChartVersionOption("chart-demo", "0.1.0"))
+		convey.So(err, convey.ShouldBeNil)
+		file, err := ioutil.ReadFile(filepath.Join(dir, "chart-demo-0.1.0.tgz"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(file, convey.ShouldResemble, chart)
+
+		// A failed download must not leave any file behind.
+		_, err = client.Charts.DownloadChart(testRepo, dir, NewChartVersionOption("chart-demo", "0.2.0"))
+		convey.So(IsNotFound(err), convey.ShouldBeTrue)
+		entries, _ := ioutil.ReadDir(dir)
+		convey.So(entries, convey.ShouldHaveLength, 1)
+
+		buf := new(bytes.Buffer)
+		_, err = client.Charts.DownloadChartTo(testRepo, buf, NewChartVersionOption("chart-demo", "0.1.0"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(buf.Bytes(), convey.ShouldResemble, chart)
+	})
+}
+
+func TestChartService_DownloadVerifiedChart(t *testing.T) {
+	convey.Convey("下载 chart 并校验 digest", t, func() {
+		srv := chartmuseumtest.NewServer()
+		defer srv.Close()
+		chart, _ := ioutil.ReadFile("./testdata/chart-demo-0.1.0.tgz")
+		sum := sha256.Sum256(chart)
+		digest := hex.EncodeToString(sum[:])
+		convey.So(srv.AddChart(testRepo, chart), convey.ShouldBeNil)
+
+		client, _ := NewClient(WithBaseURL(srv.URL))
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+		chartVersion := NewChartVersionOption("chart-demo", "0.1.0")
+		destFile := filepath.Join(dir, "chart-demo-0.1.0.tgz")
+
+		_, err = client.Charts.DownloadVerifiedChart(testRepo, dir, chartVersion, "sha256:0000")
+		convey.So(errors.Is(err, ErrDigestMismatch), convey.ShouldBeTrue)
+		entries, _ := ioutil.ReadDir(dir)
+		convey.So(entries, convey.ShouldBeEmpty)
+
+		_, err = client.Charts.DownloadVerifiedChart(testRepo, dir, chartVersion, digest)
+		convey.So(err, convey.ShouldBeNil)
+		file, _ := ioutil.ReadFile(destFile)
+		convey.So(file, convey.ShouldResemble, chart)
+		convey.So(os.Remove(destFile), convey.ShouldBeNil)
+
+		// The digest is looked up from the API when it is not given.
+		_, err = client.Charts.DownloadVerifiedChart(testRepo, dir, chartVersion, "")
+		convey.So(err, convey.ShouldBeNil)
+		file, _ = ioutil.ReadFile(destFile)
+		convey.So(file, convey.ShouldResemble, chart)
+	})
+}
+
+func TestChartService_UploadChartReader(t *testing.T) {
+	convey.Convey("从内存上传 chart", t, func() {
+		chart, _ := ioutil.ReadFile("./testdata/chart-demo-0.1.0.tgz")
+		var uploaded []byte
+		var contentType string
+		var contentLength int64
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/api/test/charts" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			contentType = r.Header.Get("Content-Type")
+			contentLength = r.ContentLength
+			uploaded, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"saved":true}`))
+		}))
+		defer ts.Close()
+
+		client, _ := NewClient(WithBaseURL(ts.URL))
+		testCases := []struct {
+			inputReader io.Reader
+			inputSize   int64
+		}{
+			{inputReader: bytes.NewBuffer(chart), inputSize: int64(len(chart))},
+			{inputReader: bytes.NewBuffer(chart), inputSize: -1},
+			{inputReader: bytes.NewReader(chart), inputSize: int64(len(chart))},
+			{inputReader: struct{ io.Reader }{bytes.NewReader(chart)}, inputSize: int64(len(chart))},
+			{inputReader: struct{ io.Reader }{bytes.NewReader(chart)}, inputSize: -1},
+		}
+		for _, tc := range testCases {
+			resp, err := client.Charts.UploadChartReader(testRepo, tc.inputReader, tc.inputSize)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+			convey.So(uploaded, convey.ShouldResemble, chart)
+			convey.So(contentType, convey.ShouldEqual, "application/x-gzip")
+			convey.So(contentLength, convey.ShouldEqual, len(chart))
+		}
+
+		// A size which doesn't match the archive is rejected before sending.
+		uploaded = nil
+		mismatched := []struct {
+			inputReader io.Reader
+			inputSize   int64
+		}{
+			{inputReader: bytes.NewBuffer(chart), inputSize: int64(len(chart)) + 1},
+			{inputReader: bytes.NewReader(chart), inputSize: int64(len(chart)) - 1},
+			{inputReader: struct{ io.Reader }{bytes.NewReader(chart)}, inputSize: int64(len(chart)) + 1},
+			{inputReader: struct{ io.Reader }{bytes.NewReader(chart)}, inputSize: int64(len(chart)) - 1},
+		}
+		for _, tc := range mismatched {
+			_, err := client.Charts.UploadChartReader(testRepo, tc.inputReader, tc.inputSize)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(uploaded, convey.ShouldBeNil)
+		}
+	})
+}
+
+func TestChartService_UploadChartForce(t *testing.T) {
+	convey.Convey("强制覆盖上传 chart", t, func() {
+		chartPath := "./testdata/chart-demo-0.1.0.tgz"
+
+		convey.Convey("允许强制覆盖", func() {
+			srv := chartmuseumtest.NewServer()
+			defer srv.Close()
+			client, _ := NewClient(WithBaseURL(srv.URL))
+
+			_, err := client.Charts.UploadChart(testRepo, chartPath)
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Charts.UploadChart(testRepo, chartPath)
+			convey.So(IsConflict(err), convey.ShouldBeTrue)
+
+			resp, err := client.Charts.UploadChart(testRepo, chartPath, WithForceOverwrite())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		})
+
+		convey.Convey("服务端禁用强制覆盖", func() {
+			srv := chartmuseumtest.NewServer(chartmuseumtest.WithDisableForceOverwrite())
+			defer srv.Close()
+			client, _ := NewClient(WithBaseURL(srv.URL))
+
+			_, err := client.Charts.UploadChart(testRepo, chartPath)
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Charts.UploadChart(testRepo, chartPath, WithForceOverwrite())
+			convey.So(IsConflict(err), convey.ShouldBeTrue)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "--disable-force-overwrite")
+		})
+	})
+}
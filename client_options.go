@@ -1,5 +1,16 @@
 package chartmuseum
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
 type ClientOptionFunc func(*Client) error
 
 // WithBaseURL sets the base URL for API requests to a custom endpoint.
@@ -9,3 +20,233 @@ func WithBaseURL(urlStr string) ClientOptionFunc {
 		return err
 	}
 }
+
+// WithTokenSource enables bearer authentication using the tokens supplied by
+// ts. The token source is consulted before each request.
+func WithTokenSource(ts TokenSource) ClientOptionFunc {
+	return func(c *Client) error {
+		c.authType = BearerAuth
+		c.tokenSource = ts
+		return nil
+	}
+}
+
+// WithCustomLimiter injects a custom rate limiter to the client.
+func WithCustomLimiter(limiter RateLimiter) ClientOptionFunc {
+	return func(c *Client) error {
+		c.limiter = limiter
+		return nil
+	}
+}
+
+// WithRateLimit limits the client to limit requests per second using a token
+// bucket which allows bursts of up to burst requests.
+func WithRateLimit(limit float64, burst int) ClientOptionFunc {
+	return func(c *Client) error {
+		if limit <= 0 {
+			return errors.New("rate limit must be positive")
+		}
+		if burst < 1 {
+			return errors.New("rate limit burst must be at least 1")
+		}
+		c.limiter = rate.NewLimiter(rate.Limit(limit), burst)
+		return nil
+	}
+}
+
+// WithRateLimitFromHeader sizes the rate limiter using the RateLimit-Limit
+// header returned by the server, which is queried once before the first
+// request. If the server does not return the header, the limiter configured
+// by the other options is kept.
+func WithRateLimitFromHeader() ClientOptionFunc {
+	return func(c *Client) error {
+		c.limiterFromHeader = true
+		return nil
+	}
+}
+
+// WithCustomRetry can be used to configure a custom retry policy, which
+// replaces the default one.
+func WithCustomRetry(checkRetry retryablehttp.CheckRetry) ClientOptionFunc {
+	return func(c *Client) error {
+		c.checkRetry = checkRetry
+		return nil
+	}
+}
+
+// WithCustomRetryMax can be used to configure a custom maximum number of retries.
+func WithCustomRetryMax(retryMax int) ClientOptionFunc {
+	return func(c *Client) error {
+		if retryMax < 0 {
+			return errors.New("retry max must not be negative")
+		}
+		c.client.RetryMax = retryMax
+		return nil
+	}
+}
+
+// WithCustomRetryWaitMinMax can be used to configure a custom minimum and
+// maximum time to wait between retries.
+func WithCustomRetryWaitMinMax(waitMin, waitMax time.Duration) ClientOptionFunc {
+	return func(c *Client) error {
+		if waitMin > waitMax {
+			return errors.New("retry wait min must not be greater than retry wait max")
+		}
+		c.client.RetryWaitMin = waitMin
+		c.client.RetryWaitMax = waitMax
+		return nil
+	}
+}
+
+// WithoutRetries disables the default retry logic.
+func WithoutRetries() ClientOptionFunc {
+	return func(c *Client) error {
+		c.disableRetries = true
+		return nil
+	}
+}
+
+// WithHTTPClient can be used to configure a custom HTTP client. Retries and
+// rate limiting are still handled on top of it. TLS options are applied to a
+// copy of it, whatever the order of the options.
+func WithHTTPClient(httpClient *http.Client) ClientOptionFunc {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("http client must not be nil")
+		}
+		c.client.HTTPClient = httpClient
+		return nil
+	}
+}
+
+// WithTransport can be used to configure a custom transport, e.g. to use a
+// proxy or custom dialer, for the HTTP client used by the client. TLS options
+// are applied to a copy of it, which must be an *http.Transport.
+func WithTransport(transport http.RoundTripper) ClientOptionFunc {
+	return func(c *Client) error {
+		if transport == nil {
+			return errors.New("transport must not be nil")
+		}
+		// Copy the client, it may have been injected by WithHTTPClient.
+		httpClient := *c.client.HTTPClient
+		httpClient.Transport = transport
+		c.client.HTTPClient = &httpClient
+		return nil
+	}
+}
+
+// WithCAFile verifies the certificate of the server using the CA bundle in
+// caFile, like the --ca-file flag of helm repo add.
+func WithCAFile(caFile string) ClientOptionFunc {
+	return func(c *Client) error {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return errors.Wrapf(err, "can't read CA file %s", caFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.Errorf("failed to append certificates from CA file %s", caFile)
+		}
+
+		c.tlsOptions = append(c.tlsOptions, func(tlsConfig *tls.Config) {
+			tlsConfig.RootCAs = pool
+		})
+		return nil
+	}
+}
+
+// WithClientCertificate identifies the client with the certificate and key
+// in certFile and keyFile, like the --cert-file and --key-file flags of helm
+// repo add.
+func WithClientCertificate(certFile, keyFile string) ClientOptionFunc {
+	return func(c *Client) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return errors.Wrap(err, "can't load client certificate")
+		}
+
+		c.tlsOptions = append(c.tlsOptions, func(tlsConfig *tls.Config) {
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		})
+		return nil
+	}
+}
+
+// WithInsecureSkipTLSVerify skips the verification of the server certificate,
+// like the --insecure-skip-tls-verify flag of helm repo add.
+func WithInsecureSkipTLSVerify(skip bool) ClientOptionFunc {
+	return func(c *Client) error {
+		c.tlsOptions = append(c.tlsOptions, func(tlsConfig *tls.Config) {
+			tlsConfig.InsecureSkipVerify = skip
+		})
+		return nil
+	}
+}
+
+// configureTLS applies the TLS options to a copy of the transport of the
+// client, once all client options have been applied, so that they don't
+// depend on the order of WithHTTPClient and WithTransport and never modify
+// an injected client or transport. Custom transports can't be configured.
+func (c *Client) configureTLS() error {
+	if len(c.tlsOptions) == 0 {
+		return nil
+	}
+
+	httpClient := *c.client.HTTPClient
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return errors.Errorf("can't configure TLS of a custom transport %T", base)
+	}
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	for _, fn := range c.tlsOptions {
+		fn(transport.TLSClientConfig)
+	}
+
+	httpClient.Transport = transport
+	c.client.HTTPClient = &httpClient
+	return nil
+}
+
+// WithPassCredentialsAll keeps sending the credentials when the server
+// redirects a request to another host, like the --pass-credentials flag of
+// helm repo add. By default, as with net/http, they are only sent to the host
+// of the base URL.
+func WithPassCredentialsAll(pass bool) ClientOptionFunc {
+	return func(c *Client) error {
+		c.passCredentialsAll = pass
+		return nil
+	}
+}
+
+// configureRedirects makes a copy of the HTTP client of the client keep the
+// Authorization header on redirects to other hosts, if passCredentialsAll is
+// set.
+func (c *Client) configureRedirects() {
+	if !c.passCredentialsAll {
+		return
+	}
+
+	httpClient := *c.client.HTTPClient
+	checkRedirect := httpClient.CheckRedirect
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if checkRedirect != nil {
+			if err := checkRedirect(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if auth := via[0].Header.Get("Authorization"); auth != "" && req.Header.Get("Authorization") == "" {
+			req.Header.Set("Authorization", auth)
+		}
+		return nil
+	}
+	c.client.HTTPClient = &httpClient
+}
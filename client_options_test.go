@@ -0,0 +1,156 @@
+package chartmuseum
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCertificate generates a self signed client certificate and
+// writes it and its key as PEM into dir.
+func writeClientCertificate(dir string) (certFile, keyFile string, cert *x509.Certificate, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-chartmuseum"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", nil, err
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		return "", "", nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		return "", "", nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return "", "", nil, err
+	}
+	return certFile, keyFile, cert, nil
+}
+
+func TestClientOptions_TLS(t *testing.T) {
+	convey.Convey("TLS 配置", t, func() {
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		certFile, keyFile, clientCert, err := writeClientCertificate(dir)
+		convey.So(err, convey.ShouldBeNil)
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(clientCert)
+
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		ts.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.VerifyClientCertIfGiven}
+		ts.StartTLS()
+		defer ts.Close()
+
+		caFile := filepath.Join(dir, "ca.crt")
+		err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("默认拒绝未知 CA 的证书", func() {
+			client, _ := NewClient(WithBaseURL(ts.URL), WithoutRetries())
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("使用 CA 文件", func() {
+			client, err := NewClient(WithBaseURL(ts.URL), WithCAFile(caFile))
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("跳过证书校验", func() {
+			client, err := NewClient(WithBaseURL(ts.URL), WithInsecureSkipTLSVerify(true))
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("使用客户端证书", func() {
+			ts.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+			client, _ := NewClient(WithBaseURL(ts.URL), WithCAFile(caFile), WithoutRetries())
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldNotBeNil)
+
+			client, err = NewClient(WithBaseURL(ts.URL), WithCAFile(caFile), WithClientCertificate(certFile, keyFile))
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("不修改注入的 Transport 和 http.Client", func() {
+			transport := &http.Transport{}
+			client, err := NewClient(WithBaseURL(ts.URL), WithInsecureSkipTLSVerify(true), WithTransport(transport))
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			// Clone sets up the HTTP/2 defaults of the original, but must not
+			// touch its certificate verification.
+			convey.So(transport.TLSClientConfig == nil || !transport.TLSClientConfig.InsecureSkipVerify, convey.ShouldBeTrue)
+
+			httpClient := &http.Client{}
+			client, err = NewClient(WithBaseURL(ts.URL), WithCAFile(caFile), WithHTTPClient(httpClient))
+			convey.So(err, convey.ShouldBeNil)
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(httpClient.Transport, convey.ShouldBeNil)
+
+			_, err = NewClient(WithHTTPClient(http.DefaultClient), WithTransport(http.DefaultTransport), WithInsecureSkipTLSVerify(true))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(http.DefaultClient.Transport, convey.ShouldBeNil)
+			defaultTLS := http.DefaultTransport.(*http.Transport).TLSClientConfig
+			convey.So(defaultTLS == nil || !defaultTLS.InsecureSkipVerify, convey.ShouldBeTrue)
+		})
+
+		convey.Convey("无法配置自定义 RoundTripper 的 TLS", func() {
+			transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return nil, nil
+			})
+			_, err := NewClient(WithTransport(transport), WithInsecureSkipTLSVerify(true))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("非法的证书文件", func() {
+			_, err := NewClient(WithCAFile(filepath.Join(dir, "missing.crt")))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = NewClient(WithCAFile(keyFile))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = NewClient(WithClientCertificate(certFile, caFile))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/pkg/errors"
+	"github.com/yidaqiang/go-chartmuseum"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNotExist makes `exists` exit with 1 without printing an error.
+var errNotExist = errors.New("does not exist")
+
+// cmdEnv is what a command runs with.
+type cmdEnv struct {
+	ctx     context.Context
+	client  *chartmuseum.Client
+	opts    *globalOptions
+	printer *printer
+}
+
+// runFunc runs a command with its positional arguments.
+type runFunc func(env *cmdEnv, args []string) error
+
+type command struct {
+	summary string
+	args    string
+	minArgs int
+	maxArgs int
+	// setup registers the flags of the command and returns the function which
+	// runs it.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = map[string]*command{
+	"list": {
+		summary: "list the charts of the repo",
+		setup:   func(*flag.FlagSet) runFunc { return runList },
+	},
+	"versions": {
+		summary: "list the versions of a chart",
+		args:    "<chart>",
+		minArgs: 1,
+		maxArgs: 1,
+		setup:   func(*flag.FlagSet) runFunc { return runVersions },
+	},
+	"show": {
+		summary: "show a chart version",
+		args:    "<chart> <version>",
+		minArgs: 2,
+		maxArgs: 2,
+		setup:   func(*flag.FlagSet) runFunc { return runShow },
+	},
+	"exists": {
+		summary: "check whether a chart or chart version exists, exits with 1 if not",
+		args:    "<chart> [version]",
+		minArgs: 1,
+		maxArgs: 2,
+		setup:   func(*flag.FlagSet) runFunc { return runExists },
+	},
+	"push": {
+		summary: "upload a packaged chart, or package and upload a chart directory",
+		args:    "<chart.tgz|chart-dir>",
+		minArgs: 1,
+		maxArgs: 1,
+		setup:   setupPush,
+	},
+	"pull": {
+		summary: "download a chart version, the latest one by default",
+		args:    "<chart> [version]",
+		minArgs: 1,
+		maxArgs: 2,
+		setup:   setupPull,
+	},
+	"delete": {
+		summary: "delete a chart version",
+		args:    "<chart> <version>",
+		minArgs: 2,
+		maxArgs: 2,
+		setup:   func(*flag.FlagSet) runFunc { return runDelete },
+	},
+	"latest": {
+		summary: "print the newest version of a chart matching a semver constraint",
+		args:    "<chart>",
+		minArgs: 1,
+		maxArgs: 1,
+		setup:   setupLatest,
+	},
+	"health": {
+		summary: "check the health of the server, exits with 1 if unhealthy",
+		setup:   func(*flag.FlagSet) runFunc { return runHealth },
+	},
+	"info": {
+		summary: "print the version of the server",
+		setup:   func(*flag.FlagSet) runFunc { return runInfo },
+	},
+}
+
+func runList(env *cmdEnv, args []string) error {
+	charts, _, err := env.client.Charts.ListChartsContext(env.ctx, env.opts.repo)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(*charts))
+	for name := range *charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		cvs := (*charts)[name]
+		if len(cvs) == 0 {
+			continue
+		}
+		latest := cvs[0]
+		rows = append(rows, []string{name, latest.Version, latest.AppVersion, strconv.Itoa(len(cvs)), latest.Description})
+	}
+	return env.printer.print(charts, []string{"NAME", "LATEST VERSION", "APP VERSION", "VERSIONS", "DESCRIPTION"}, rows)
+}
+
+func runVersions(env *cmdEnv, args []string) error {
+	cvs, _, err := env.client.Charts.ListVersionsContext(env.ctx, env.opts.repo, chartmuseum.NewChartOption(args[0]))
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(*cvs))
+	for _, cv := range *cvs {
+		rows = append(rows, []string{cv.Version, cv.AppVersion, formatTime(cv.Created), cv.Digest})
+	}
+	return env.printer.print(cvs, []string{"VERSION", "APP VERSION", "CREATED", "DIGEST"}, rows)
+}
+
+func runShow(env *cmdEnv, args []string) error {
+	cv, _, err := env.client.Charts.GetVersionContext(env.ctx, env.opts.repo, chartmuseum.NewChartVersionOption(args[0], args[1]))
+	if err != nil {
+		return err
+	}
+	return env.printer.print(cv, nil, [][]string{
+		{"Name:", cv.Name},
+		{"Version:", cv.Version},
+		{"App version:", cv.AppVersion},
+		{"Description:", cv.Description},
+		{"Created:", formatTime(cv.Created)},
+		{"Digest:", cv.Digest},
+		{"URLs:", strings.Join(cv.URLs, ", ")},
+	})
+}
+
+func runExists(env *cmdEnv, args []string) error {
+	var (
+		exists bool
+		err    error
+	)
+	if len(args) == 2 {
+		exists, _, err = env.client.Charts.IsExistVersionContext(env.ctx, env.opts.repo, chartmuseum.NewChartVersionOption(args[0], args[1]))
+	} else {
+		exists, _, err = env.client.Charts.IsExistContext(env.ctx, env.opts.repo, chartmuseum.NewChartOption(args[0]))
+	}
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Exists bool `json:"exists"`
+	}{exists}
+	if err := env.printer.print(result, nil, [][]string{{strconv.FormatBool(exists)}}); err != nil {
+		return err
+	}
+	if !exists {
+		return errNotExist
+	}
+	return nil
+}
+
+// pushResult is the output of push.
+type pushResult struct {
+	Repo    string `json:"repo"`
+	Chart   string `json:"chart"`
+	Version string `json:"version"`
+}
+
+func setupPush(fs *flag.FlagSet) runFunc {
+	prov := fs.String("prov", "", "also upload this provenance file")
+	force := fs.Bool("force", false, "overwrite an existing chart version")
+	version := fs.String("version", "", "override the chart version when packaging a directory")
+	appVersion := fs.String("app-version", "", "override the app version when packaging a directory")
+
+	return func(env *cmdEnv, args []string) error {
+		path := args[0]
+		ch, err := loader.Load(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load chart %s", path)
+		}
+		result := pushResult{Repo: env.opts.repo, Chart: ch.Metadata.Name, Version: ch.Metadata.Version}
+
+		var options []chartmuseum.RequestOptionFunc
+		if *force {
+			options = append(options, chartmuseum.WithForceOverwrite())
+		}
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case stat.IsDir():
+			if *prov != "" {
+				return errors.New("--prov requires a packaged chart")
+			}
+			opts := chartmuseum.PackageOption{}
+			if *version != "" {
+				opts.Version = version
+				result.Version = *version
+			}
+			if *appVersion != "" {
+				opts.AppVersion = appVersion
+			}
+			_, err = env.client.Charts.PackageAndUploadContext(env.ctx, env.opts.repo, path, opts, options...)
+		case *version != "" || *appVersion != "":
+			return errors.New("--version and --app-version require a chart directory")
+		case *prov != "":
+			_, err = env.client.Charts.UploadChartWithProvenanceContext(env.ctx, env.opts.repo, path, *prov, options...)
+		default:
+			_, err = env.client.Charts.UploadChartContext(env.ctx, env.opts.repo, path, options...)
+		}
+		if err != nil {
+			return err
+		}
+		return env.printer.print(result, []string{"REPO", "CHART", "VERSION"}, [][]string{{result.Repo, result.Chart, result.Version}})
+	}
+}
+
+// pullResult is the output of pull.
+type pullResult struct {
+	Chart      string `json:"chart"`
+	Version    string `json:"version"`
+	Path       string `json:"path"`
+	Provenance string `json:"provenance,omitempty"`
+}
+
+func setupPull(fs *flag.FlagSet) runFunc {
+	dest := fs.String("dest", ".", "directory to download the chart to")
+	prov := fs.Bool("prov", false, "also download the provenance file")
+	verify := fs.Bool("verify", false, "verify the chart against the digest published by the repo")
+
+	return func(env *cmdEnv, args []string) error {
+		name := args[0]
+		var version string
+		if len(args) == 2 {
+			version = args[1]
+		} else {
+			cv, _, err := env.client.Charts.ResolveVersionContext(env.ctx, env.opts.repo, chartmuseum.NewChartOption(name), "", false)
+			if err != nil {
+				return err
+			}
+			version = cv.Version
+		}
+
+		cvo := chartmuseum.NewChartVersionOption(name, version)
+		var err error
+		if *verify {
+			_, err = env.client.Charts.DownloadVerifiedChartContext(env.ctx, env.opts.repo, *dest, cvo, "")
+		} else {
+			_, err = env.client.Charts.DownloadChartContext(env.ctx, env.opts.repo, *dest, cvo)
+		}
+		if err != nil {
+			return err
+		}
+		result := pullResult{Chart: name, Version: version, Path: filepath.Join(*dest, name+"-"+version+".tgz")}
+		if *prov {
+			if _, err := env.client.Charts.DownloadProvenanceContext(env.ctx, env.opts.repo, *dest, cvo); err != nil {
+				return err
+			}
+			result.Provenance = result.Path + ".prov"
+		}
+		return env.printer.print(result, []string{"CHART", "VERSION", "PATH"}, [][]string{{result.Chart, result.Version, result.Path}})
+	}
+}
+
+func runDelete(env *cmdEnv, args []string) error {
+	_, err := env.client.Charts.DeleteChartContext(env.ctx, env.opts.repo, chartmuseum.NewChartVersionOption(args[0], args[1]))
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Chart   string `json:"chart"`
+		Version string `json:"version"`
+		Deleted bool   `json:"deleted"`
+	}{args[0], args[1], true}
+	return env.printer.print(result, []string{"CHART", "VERSION", "DELETED"}, [][]string{{result.Chart, result.Version, "true"}})
+}
+
+func setupLatest(fs *flag.FlagSet) runFunc {
+	constraint := fs.String("constraint", "", `semver constraint, e.g. "^1.2" or ">=1.0 <2.0"`)
+	devel := fs.Bool("devel", false, "include prereleases")
+
+	return func(env *cmdEnv, args []string) error {
+		cv, _, err := env.client.Charts.ResolveVersionContext(env.ctx, env.opts.repo, chartmuseum.NewChartOption(args[0]), *constraint, *devel)
+		if err != nil {
+			return err
+		}
+		return env.printer.print(cv, nil, [][]string{{cv.Version}})
+	}
+}
+
+func runHealth(env *cmdEnv, args []string) error {
+	health, err := env.client.Info.HealthContext(env.ctx)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Healthy bool `json:"healthy"`
+	}{health.Healthy}
+	if err := env.printer.print(result, []string{"HEALTHY"}, [][]string{{strconv.FormatBool(health.Healthy)}}); err != nil {
+		return err
+	}
+	if !health.Healthy {
+		return errors.New("server is unhealthy")
+	}
+	return nil
+}
+
+func runInfo(env *cmdEnv, args []string) error {
+	info, err := env.client.Info.InfoContext(env.ctx)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Version string `json:"version"`
+	}{info.Version}
+	return env.printer.print(result, []string{"VERSION"}, [][]string{{info.Version}})
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
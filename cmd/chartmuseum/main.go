@@ -0,0 +1,267 @@
+// Command chartmuseum is a command-line client for ChartMuseum built on the
+// go-chartmuseum library.
+//
+// Usage:
+//
+//	chartmuseum [global flags] <command> [flags] [args]
+//
+// Global flags may also be given after the command. Each of them falls back to
+// an environment variable, e.g. --url to CHARTMUSEUM_URL; run
+// `chartmuseum help` for the full list.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/yidaqiang/go-chartmuseum"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// globalOptions are the flags shared by every command.
+type globalOptions struct {
+	url      string
+	helmRepo string
+	username string
+	password string
+	token    string
+	repo     string
+	output   string
+	caFile   string
+	insecure bool
+	retries  int
+	timeout  time.Duration
+	debug    bool
+}
+
+// defaultOptions returns the defaults of the global options, taken from the
+// environment.
+func defaultOptions() *globalOptions {
+	return &globalOptions{
+		url:      os.Getenv("CHARTMUSEUM_URL"),
+		helmRepo: os.Getenv("CHARTMUSEUM_HELM_REPO"),
+		username: os.Getenv("CHARTMUSEUM_USERNAME"),
+		password: os.Getenv("CHARTMUSEUM_PASSWORD"),
+		token:    os.Getenv("CHARTMUSEUM_TOKEN"),
+		repo:     os.Getenv("CHARTMUSEUM_REPO"),
+		output:   envString("CHARTMUSEUM_OUTPUT", "table"),
+		caFile:   os.Getenv("CHARTMUSEUM_CA_FILE"),
+		insecure: envBool("CHARTMUSEUM_INSECURE_SKIP_TLS_VERIFY", false),
+		retries:  envInt("CHARTMUSEUM_RETRIES", 3),
+		timeout:  envDuration("CHARTMUSEUM_TIMEOUT", 0),
+		debug:    envBool("CHARTMUSEUM_DEBUG", false),
+	}
+}
+
+// register adds the global flags to fs, defaulting to the current values so
+// that flags given before the command are kept.
+func (o *globalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.url, "url", o.url, "ChartMuseum server URL ($CHARTMUSEUM_URL)")
+	fs.StringVar(&o.helmRepo, "helm-repo", o.helmRepo, "read URL and credentials of the named repository from helm's repositories.yaml ($CHARTMUSEUM_HELM_REPO)")
+	fs.StringVar(&o.username, "username", o.username, "basic auth username ($CHARTMUSEUM_USERNAME)")
+	fs.Var(&secretValue{&o.password}, "password", "basic auth password ($CHARTMUSEUM_PASSWORD)")
+	fs.Var(&secretValue{&o.token}, "token", "bearer token ($CHARTMUSEUM_TOKEN)")
+	fs.StringVar(&o.repo, "repo", o.repo, "chart repository, e.g. org/team ($CHARTMUSEUM_REPO)")
+	fs.StringVar(&o.output, "o", o.output, "output format: table, json or yaml ($CHARTMUSEUM_OUTPUT)")
+	fs.StringVar(&o.output, "output", o.output, "same as -o")
+	fs.StringVar(&o.caFile, "ca-file", o.caFile, "verify the server certificate with this CA bundle ($CHARTMUSEUM_CA_FILE)")
+	fs.BoolVar(&o.insecure, "insecure-skip-tls-verify", o.insecure, "skip verification of the server certificate ($CHARTMUSEUM_INSECURE_SKIP_TLS_VERIFY)")
+	fs.IntVar(&o.retries, "retries", o.retries, "maximum number of retries of a failed request ($CHARTMUSEUM_RETRIES)")
+	fs.DurationVar(&o.timeout, "timeout", o.timeout, "timeout of the command, 0 for none ($CHARTMUSEUM_TIMEOUT)")
+	fs.BoolVar(&o.debug, "debug", o.debug, "log every request ($CHARTMUSEUM_DEBUG)")
+}
+
+// secretValue is a string flag which never shows its value in the usage, so
+// that a password from the environment is not printed as the default.
+type secretValue struct {
+	p *string
+}
+
+func (s *secretValue) String() string {
+	return ""
+}
+
+func (s *secretValue) Set(v string) error {
+	*s.p = v
+	return nil
+}
+
+// newClient creates the client for the global options. When --helm-repo is
+// set and --repo is not, the repo is taken from the helm repository URL.
+func (o *globalOptions) newClient(stderr io.Writer) (*chartmuseum.Client, error) {
+	logger := logrus.New()
+	logger.SetOutput(stderr)
+	if o.debug {
+		logger.SetLevel(logrus.DebugLevel)
+	}
+	options := []chartmuseum.ClientOptionFunc{
+		chartmuseum.WithLogger(chartmuseum.NewLogrusLogger(logger)),
+		chartmuseum.WithCustomRetryMax(o.retries),
+	}
+	if o.caFile != "" {
+		options = append(options, chartmuseum.WithCAFile(o.caFile))
+	}
+	if o.insecure {
+		options = append(options, chartmuseum.WithInsecureSkipTLSVerify(true))
+	}
+
+	if o.helmRepo != "" {
+		client, repo, err := chartmuseum.NewClientFromHelmRepo(o.helmRepo, options...)
+		if err != nil {
+			return nil, err
+		}
+		if o.repo == "" {
+			o.repo = repo
+		}
+		return client, nil
+	}
+
+	if o.url == "" {
+		return nil, errors.New("no server URL, set --url or CHARTMUSEUM_URL")
+	}
+	options = append(options, chartmuseum.WithBaseURL(o.url))
+	switch {
+	case o.token != "":
+		return chartmuseum.NewTokenClient(o.token, options...)
+	case o.username != "":
+		return chartmuseum.NewBasicAuthClient(o.username, o.password, options...)
+	default:
+		return chartmuseum.NewClient(options...)
+	}
+}
+
+// context returns the context the command runs with, bounded by --timeout.
+func (o *globalOptions) context() (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(context.Background(), o.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code: 0 on success,
+// 1 on failure, 2 on invalid usage. `exists` exits with 1 when the chart does
+// not exist.
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := defaultOptions()
+	global := flag.NewFlagSet("chartmuseum", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	opts.register(global)
+	global.Usage = func() { usage(stderr, global) }
+	if err := global.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if global.NArg() == 0 {
+		usage(stderr, global)
+		return 2
+	}
+	name := global.Arg(0)
+	if name == "help" {
+		usage(stdout, global)
+		return 0
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Error: unknown command %q\n\n", name)
+		usage(stderr, global)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("chartmuseum "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.register(fs)
+	runCmd := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: chartmuseum %s [flags] %s\n\n%s\n\nFlags:\n", name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(global.Args()[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() < cmd.minArgs || fs.NArg() > cmd.maxArgs {
+		fmt.Fprintf(stderr, "Error: %q takes %s\n\n", name, cmd.args)
+		fs.Usage()
+		return 2
+	}
+
+	p, err := newPrinter(opts.output, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return 2
+	}
+	client, err := opts.newClient(stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return 1
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+
+	env := &cmdEnv{ctx: ctx, client: client, opts: opts, printer: p}
+	if err := runCmd(env, fs.Args()); err != nil {
+		if err != errNotExist {
+			fmt.Fprintf(stderr, "Error: %s\n", err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: chartmuseum [global flags] <command> [flags] [args]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(w, "\nGlobal flags:\n")
+	global.SetOutput(w)
+	global.PrintDefaults()
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
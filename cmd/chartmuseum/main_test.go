@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yidaqiang/go-chartmuseum/chartmuseumtest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	convey.Convey("命令行工具", t, func() {
+		srv := chartmuseumtest.NewServer(chartmuseumtest.WithBasicAuth("admin", "password"))
+		defer srv.Close()
+		convey.So(srv.AddChartVersion("org/team", "mysql", "8.8.19"), convey.ShouldBeNil)
+		convey.So(srv.AddChartVersion("org/team", "mysql", "9.3.4"), convey.ShouldBeNil)
+		convey.So(srv.AddChartVersion("org/team", "mysql", "9.4.0-rc.1"), convey.ShouldBeNil)
+
+		os.Setenv("CHARTMUSEUM_URL", srv.URL)
+		os.Setenv("CHARTMUSEUM_USERNAME", "admin")
+		os.Setenv("CHARTMUSEUM_PASSWORD", "password")
+		defer os.Unsetenv("CHARTMUSEUM_URL")
+		defer os.Unsetenv("CHARTMUSEUM_USERNAME")
+		defer os.Unsetenv("CHARTMUSEUM_PASSWORD")
+
+		dir, err := ioutil.TempDir("", "chartmuseum-cli")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		convey.Convey("查询 chart", func() {
+			code, stdout, _ := runCommand("--repo", "org/team", "list")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldStartWith, "NAME")
+			convey.So(stdout, convey.ShouldContainSubstring, "mysql")
+
+			code, stdout, _ = runCommand("-o", "json", "versions", "--repo", "org/team", "mysql")
+			convey.So(code, convey.ShouldEqual, 0)
+			var versions []map[string]interface{}
+			convey.So(json.Unmarshal([]byte(stdout), &versions), convey.ShouldBeNil)
+			convey.So(len(versions), convey.ShouldEqual, 3)
+
+			code, stdout, _ = runCommand("--repo", "org/team", "-o", "yaml", "show", "mysql", "9.3.4")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldContainSubstring, "version: 9.3.4")
+
+			code, stdout, _ = runCommand("--repo", "org/team", "latest", "mysql")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldEqual, "9.3.4\n")
+
+			code, stdout, _ = runCommand("--repo", "org/team", "latest", "--devel", "--constraint", "^9", "mysql")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldEqual, "9.4.0-rc.1\n")
+		})
+
+		convey.Convey("检查 chart 是否存在", func() {
+			code, stdout, _ := runCommand("--repo", "org/team", "exists", "mysql", "9.3.4")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldEqual, "true\n")
+
+			code, stdout, stderr := runCommand("--repo", "org/team", "exists", "redis")
+			convey.So(code, convey.ShouldEqual, 1)
+			convey.So(stdout, convey.ShouldEqual, "false\n")
+			convey.So(stderr, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("上传、下载和删除 chart", func() {
+			code, stdout, stderr := runCommand("--repo", "test", "push", "../../testdata/chart-demo-0.1.0.tgz")
+			convey.So(stderr, convey.ShouldBeEmpty)
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldContainSubstring, "chart-demo")
+
+			code, _, stderr = runCommand("--repo", "test", "push", "../../testdata/chart-demo-0.1.0.tgz")
+			convey.So(code, convey.ShouldEqual, 1)
+			convey.So(stderr, convey.ShouldContainSubstring, "409")
+			code, _, _ = runCommand("--repo", "test", "push", "--force", "../../testdata/chart-demo-0.1.0.tgz")
+			convey.So(code, convey.ShouldEqual, 0)
+
+			code, _, stderr = runCommand("--repo", "test", "pull", "--verify", "--dest", dir, "chart-demo")
+			convey.So(stderr, convey.ShouldBeEmpty)
+			convey.So(code, convey.ShouldEqual, 0)
+			archive, _ := srv.Chart("test", "chart-demo", "0.1.0")
+			pulled, err := ioutil.ReadFile(filepath.Join(dir, "chart-demo-0.1.0.tgz"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(pulled, convey.ShouldResemble, archive)
+
+			code, _, _ = runCommand("--repo", "test", "delete", "chart-demo", "0.1.0")
+			convey.So(code, convey.ShouldEqual, 0)
+			_, ok := srv.Chart("test", "chart-demo", "0.1.0")
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+
+		convey.Convey("服务状态", func() {
+			code, stdout, _ := runCommand("health")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldEqual, "HEALTHY\ntrue\n")
+
+			code, stdout, _ = runCommand("info", "-o", "json")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(strings.TrimSpace(stdout), convey.ShouldEqual, `{
+  "version": "`+chartmuseumtest.DefaultVersion+`"
+}`)
+
+			code, _, stderr := runCommand("--password", "wrong", "info")
+			convey.So(code, convey.ShouldEqual, 1)
+			convey.So(stderr, convey.ShouldContainSubstring, "401")
+		})
+
+		convey.Convey("错误的用法", func() {
+			code, _, stderr := runCommand("unknown")
+			convey.So(code, convey.ShouldEqual, 2)
+			convey.So(stderr, convey.ShouldContainSubstring, `unknown command "unknown"`)
+
+			code, _, _ = runCommand("show", "mysql")
+			convey.So(code, convey.ShouldEqual, 2)
+
+			code, _, stderr = runCommand("-o", "xml", "list")
+			convey.So(code, convey.ShouldEqual, 2)
+			convey.So(stderr, convey.ShouldContainSubstring, "unknown output format")
+
+			code, stdout, _ := runCommand("help")
+			convey.So(code, convey.ShouldEqual, 0)
+			convey.So(stdout, convey.ShouldContainSubstring, "CHARTMUSEUM_URL")
+			convey.So(stdout, convey.ShouldNotContainSubstring, "password\"")
+		})
+	})
+}
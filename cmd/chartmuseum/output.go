@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes the result of a command in the selected output format.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+func newPrinter(format string, w io.Writer) (*printer, error) {
+	switch format {
+	case "table", "json", "yaml":
+		return &printer{format: format, w: w}, nil
+	default:
+		return nil, errors.Errorf("unknown output format %q, must be one of table, json or yaml", format)
+	}
+}
+
+// print writes v as JSON or YAML, or the rows as a table. The header line of
+// the table is omitted when headers is nil.
+func (p *printer) print(v interface{}, headers []string, rows [][]string) error {
+	switch p.format {
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", data)
+		return err
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = p.w.Write(data)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	if headers != nil {
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,118 @@
+package chartmuseum
+
+import (
+	"context"
+	helmrepo "helm.sh/helm/v3/pkg/repo"
+	"io"
+)
+
+// The methods below are the context-first variants of the ChartService and
+// InfoService methods. The request is bound to ctx, which takes precedence
+// over a WithContext option: cancelling ctx aborts waiting for the rate
+// limiter or a retry, and aborts the transfer of the request and response
+// bodies, e.g. of a streaming download.
+
+// ListChartsContext is like ListCharts, but runs the request with ctx.
+func (c *ChartService) ListChartsContext(ctx context.Context, repo string, options ...RequestOptionFunc) (*map[string]helmrepo.ChartVersions, *Response, error) {
+	return c.ListCharts(repo, appendOptions(options, WithContext(ctx))...)
+}
+
+// ListVersionsContext is like ListVersions, but runs the request with ctx.
+func (c *ChartService) ListVersionsContext(ctx context.Context, repo string, chartOptions ChartOption, options ...RequestOptionFunc) (*helmrepo.ChartVersions, *Response, error) {
+	return c.ListVersions(repo, chartOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// GetVersionContext is like GetVersion, but runs the request with ctx.
+func (c *ChartService) GetVersionContext(ctx context.Context, repo string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*helmrepo.ChartVersion, *Response, error) {
+	return c.GetVersion(repo, chartVersionOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// IsExistContext is like IsExist, but runs the request with ctx.
+func (c *ChartService) IsExistContext(ctx context.Context, repo string, chartOptions ChartOption, options ...RequestOptionFunc) (bool, *Response, error) {
+	return c.IsExist(repo, chartOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// IsExistVersionContext is like IsExistVersion, but runs the request with ctx.
+func (c *ChartService) IsExistVersionContext(ctx context.Context, repo string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (bool, *Response, error) {
+	return c.IsExistVersion(repo, chartVersionOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// GetIndexContext is like GetIndex, but runs the request with ctx.
+func (c *ChartService) GetIndexContext(ctx context.Context, repo string, options ...RequestOptionFunc) (*helmrepo.IndexFile, *Response, error) {
+	return c.GetIndex(repo, appendOptions(options, WithContext(ctx))...)
+}
+
+// DownloadChartContext is like DownloadChart, but runs the request with ctx.
+func (c *ChartService) DownloadChartContext(ctx context.Context, repo string, dest string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
+	return c.DownloadChart(repo, dest, chartVersionOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// DownloadVerifiedChartContext is like DownloadVerifiedChart, but runs the request with ctx.
+func (c *ChartService) DownloadVerifiedChartContext(ctx context.Context, repo string, dest string, chartVersionOptions ChartVersionOption, digest string, options ...RequestOptionFunc) (*Response, error) {
+	return c.DownloadVerifiedChart(repo, dest, chartVersionOptions, digest, appendOptions(options, WithContext(ctx))...)
+}
+
+// DownloadProvenanceContext is like DownloadProvenance, but runs the request with ctx.
+func (c *ChartService) DownloadProvenanceContext(ctx context.Context, repo string, dest string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
+	return c.DownloadProvenance(repo, dest, chartVersionOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// DownloadChartToContext is like DownloadChartTo, but runs the request with ctx.
+func (c *ChartService) DownloadChartToContext(ctx context.Context, repo string, w io.Writer, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
+	return c.DownloadChartTo(repo, w, chartVersionOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// UploadChartContext is like UploadChart, but runs the request with ctx.
+func (c *ChartService) UploadChartContext(ctx context.Context, repo, chartFilePath string, options ...RequestOptionFunc) (*Response, error) {
+	return c.UploadChart(repo, chartFilePath, appendOptions(options, WithContext(ctx))...)
+}
+
+// UploadChartReaderContext is like UploadChartReader, but runs the request with ctx.
+func (c *ChartService) UploadChartReaderContext(ctx context.Context, repo string, reader io.Reader, size int64, options ...RequestOptionFunc) (*Response, error) {
+	return c.UploadChartReader(repo, reader, size, appendOptions(options, WithContext(ctx))...)
+}
+
+// UploadChartWithProvenanceContext is like UploadChartWithProvenance, but runs the request with ctx.
+func (c *ChartService) UploadChartWithProvenanceContext(ctx context.Context, repo, chartFilePath, provFilePath string, options ...RequestOptionFunc) (*Response, error) {
+	return c.UploadChartWithProvenance(repo, chartFilePath, provFilePath, appendOptions(options, WithContext(ctx))...)
+}
+
+// UploadProvenanceContext is like UploadProvenance, but runs the request with ctx.
+func (c *ChartService) UploadProvenanceContext(ctx context.Context, repo, provFilePath string, options ...RequestOptionFunc) (*Response, error) {
+	return c.UploadProvenance(repo, provFilePath, appendOptions(options, WithContext(ctx))...)
+}
+
+// DeleteChartContext is like DeleteChart, but runs the request with ctx.
+func (c *ChartService) DeleteChartContext(ctx context.Context, repo string, chartVersionOptions ChartVersionOption, options ...RequestOptionFunc) (*Response, error) {
+	return c.DeleteChart(repo, chartVersionOptions, appendOptions(options, WithContext(ctx))...)
+}
+
+// ResolveVersionContext is like ResolveVersion, but runs the request with ctx.
+func (c *ChartService) ResolveVersionContext(ctx context.Context, repo string, chart ChartOption, constraint string, includePrereleases bool, options ...RequestOptionFunc) (*helmrepo.ChartVersion, *Response, error) {
+	return c.ResolveVersion(repo, chart, constraint, includePrereleases, appendOptions(options, WithContext(ctx))...)
+}
+
+// GetLatestChartVersionWithRegexContext is like GetLatestChartVersionWithRegex, but runs the request with ctx.
+func (c *ChartService) GetLatestChartVersionWithRegexContext(ctx context.Context, repo string, chart ChartOption, regex string, options ...RequestOptionFunc) (version string, err error) {
+	return c.GetLatestChartVersionWithRegex(repo, chart, regex, appendOptions(options, WithContext(ctx))...)
+}
+
+// PackageAndUploadContext is like PackageAndUpload, but runs the request with ctx.
+func (c *ChartService) PackageAndUploadContext(ctx context.Context, repo, chartDir string, opts PackageOption, options ...RequestOptionFunc) (*Response, error) {
+	return c.PackageAndUpload(repo, chartDir, opts, appendOptions(options, WithContext(ctx))...)
+}
+
+// IndexContext is like Index, but runs the request with ctx.
+func (s *InfoService) IndexContext(ctx context.Context, options ...RequestOptionFunc) (string, error) {
+	return s.Index(appendOptions(options, WithContext(ctx))...)
+}
+
+// HealthContext is like Health, but runs the request with ctx.
+func (s *InfoService) HealthContext(ctx context.Context, options ...RequestOptionFunc) (*Healthy, error) {
+	return s.Health(appendOptions(options, WithContext(ctx))...)
+}
+
+// InfoContext is like Info, but runs the request with ctx.
+func (s *InfoService) InfoContext(ctx context.Context, options ...RequestOptionFunc) (*Version, error) {
+	return s.Info(appendOptions(options, WithContext(ctx))...)
+}
@@ -0,0 +1,70 @@
+package chartmuseum
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestChartService_Context(t *testing.T) {
+	convey.Convey("通过 context 取消请求", t, func() {
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/test/charts/chart-demo-0.1.0.tgz":
+				// Send the first part of the archive and stall.
+				w.Write(make([]byte, 1024))
+				w.(http.Flusher).Flush()
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			default:
+				w.Write([]byte(`{"healthy":true}`))
+			}
+		}))
+		defer ts.Close()
+		defer close(release)
+
+		client, _ := NewClient(WithBaseURL(ts.URL))
+
+		convey.Convey("正常请求", func() {
+			health, err := client.Info.HealthContext(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(health.Healthy, convey.ShouldBeTrue)
+		})
+
+		convey.Convey("不修改调用方传入的 options", func() {
+			options := make([]RequestOptionFunc, 0, 1)
+			_, err := client.Info.HealthContext(context.Background(), options...)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(options[:1][0], convey.ShouldBeNil)
+		})
+
+		convey.Convey("已取消的 context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := client.Info.HealthContext(ctx)
+			convey.So(errors.Is(err, context.Canceled), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("下载过程中取消", func() {
+			dir, err := ioutil.TempDir("", "chartmuseum")
+			convey.So(err, convey.ShouldBeNil)
+			defer os.RemoveAll(dir)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_, err = client.Charts.DownloadChartContext(ctx, testRepo, dir, NewChartVersionOption("chart-demo", "0.1.0"))
+			convey.So(errors.Is(err, context.DeadlineExceeded), convey.ShouldBeTrue)
+			entries, _ := ioutil.ReadDir(dir)
+			convey.So(entries, convey.ShouldBeEmpty)
+		})
+	})
+}
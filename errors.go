@@ -0,0 +1,39 @@
+package chartmuseum
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors matched by an *ErrorResponse with the corresponding status
+// code. Use them with errors.Is or the helpers below.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// ErrDigestMismatch is returned when a downloaded chart does not match the
+// digest published by the repository.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// IsNotFound reports whether err was caused by a 404 Not Found response.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict reports whether err was caused by a 409 Conflict response, e.g.
+// when uploading a chart version which already exists.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsUnauthorized reports whether err was caused by a 401 Unauthorized response.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsForbidden reports whether err was caused by a 403 Forbidden response.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
@@ -0,0 +1,51 @@
+package chartmuseum
+
+import (
+	"github.com/pkg/errors"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorResponse(t *testing.T) {
+	convey.Convey("根据状态码区分 API 错误", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/test/charts/mysql/0.1.0":
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"improper constraint: 0.1.0"}`))
+			case "/api/test/charts":
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"error":"file already exists"}`))
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`unauthorized`))
+			}
+		}))
+		defer ts.Close()
+
+		client, _ := NewClient(WithBaseURL(ts.URL))
+
+		_, _, err := client.Charts.GetVersion(testRepo, NewChartVersionOption("mysql", "0.1.0"))
+		convey.So(IsNotFound(err), convey.ShouldBeTrue)
+		convey.So(IsConflict(err), convey.ShouldBeFalse)
+		var errResp *ErrorResponse
+		convey.So(errors.As(errors.Wrap(err, "wrapped"), &errResp), convey.ShouldBeTrue)
+		convey.So(errResp.ErrorMessage, convey.ShouldEqual, "improper constraint: 0.1.0")
+		convey.So(IsNotFound(errors.Wrap(err, "wrapped")), convey.ShouldBeTrue)
+
+		_, err = client.Charts.UploadChart(testRepo, "./testdata/chart-demo-0.1.0.tgz")
+		convey.So(IsConflict(err), convey.ShouldBeTrue)
+		convey.So(errors.Is(err, ErrConflict), convey.ShouldBeTrue)
+
+		_, err = client.Info.Health()
+		convey.So(IsUnauthorized(err), convey.ShouldBeTrue)
+		convey.So(IsNotFound(err), convey.ShouldBeFalse)
+		convey.So(errors.As(err, &errResp), convey.ShouldBeTrue)
+		convey.So(errResp.ErrorMessage, convey.ShouldBeEmpty)
+
+		convey.So(IsNotFound(errors.New("not found")), convey.ShouldBeFalse)
+		convey.So(IsNotFound(nil), convey.ShouldBeFalse)
+	})
+}
@@ -360,22 +360,35 @@ func renameFallback(err error, src, dst string) error {
 // AtomicWriteFile atomically (as atomic as os.Rename allows) writes a file to a
 // disk.
 func AtomicWriteFile(filename string, reader io.Reader, mode os.FileMode) error {
+	return atomicWriteFileFunc(filename, mode, func(w io.Writer) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
+}
+
+// atomicWriteFileFunc lets write stream the content into a temporary file
+// next to filename, which is then renamed to filename. The temporary file is
+// removed if write fails.
+func atomicWriteFileFunc(filename string, mode os.FileMode, write func(w io.Writer) error) error {
 	tempFile, err := ioutil.TempFile(filepath.Split(filename))
 	if err != nil {
 		return err
 	}
 	tempName := tempFile.Name()
 
-	if _, err := io.Copy(tempFile, reader); err != nil {
+	if err := write(tempFile); err != nil {
 		tempFile.Close() // return value is ignored as we are already on error path
+		os.Remove(tempName)
 		return err
 	}
 
 	if err := tempFile.Close(); err != nil {
+		os.Remove(tempName)
 		return err
 	}
 
 	if err := os.Chmod(tempName, mode); err != nil {
+		os.Remove(tempName)
 		return err
 	}
 
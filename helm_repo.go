@@ -0,0 +1,115 @@
+package chartmuseum
+
+import (
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/helmpath"
+	helmrepo "helm.sh/helm/v3/pkg/repo"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// helmRepositoryConfigEnv overrides the location of repositories.yaml, as it
+// does for helm.
+const helmRepositoryConfigEnv = "HELM_REPOSITORY_CONFIG"
+
+// helmRepoFile is the content of helm's repositories.yaml. It is parsed on
+// our own since the Entry of helm v3.4 does not know about pass_credentials_all.
+type helmRepoFile struct {
+	Repositories []*helmRepoEntry `json:"repositories"`
+}
+
+type helmRepoEntry struct {
+	helmrepo.Entry
+	PassCredentialsAll bool `json:"pass_credentials_all"`
+}
+
+// HelmRepositoryConfig returns the path of helm's repositories.yaml, honoring
+// the HELM_REPOSITORY_CONFIG environment variable.
+func HelmRepositoryConfig() string {
+	if v, ok := os.LookupEnv(helmRepositoryConfigEnv); ok {
+		return v
+	}
+	return helmpath.ConfigPath("repositories.yaml")
+}
+
+// NewClientFromHelmRepo returns a new ChartMuseum API client configured from
+// the repository called name in helm's repositories.yaml, see
+// NewClientFromHelmRepoFile.
+func NewClientFromHelmRepo(name string, options ...ClientOptionFunc) (*Client, string, error) {
+	return NewClientFromHelmRepoFile(HelmRepositoryConfig(), name, options...)
+}
+
+// NewClientFromHelmRepoFile returns a new ChartMuseum API client configured
+// from the repository called name in the given repositories.yaml. The base
+// URL, basic auth credentials, TLS files and pass-credentials setting are
+// taken from the entry, the given options are applied afterwards.
+//
+// The base URL is set to the root of the server and the path of the
+// repository URL is returned as repo, ready to be passed to the ChartService
+// methods. Servers running with a --context-path need WithBaseURL to set the
+// context path and the repo to be adjusted accordingly.
+func NewClientFromHelmRepoFile(repositoryConfig, name string, options ...ClientOptionFunc) (*Client, string, error) {
+	entry, err := loadHelmRepoEntry(repositoryConfig, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid url of helm repository %s", name)
+	}
+	repo := strings.Trim(u.Path, "/")
+	u.Path, u.RawPath, u.RawQuery, u.Fragment = "", "", "", ""
+
+	entryOptions := []ClientOptionFunc{
+		WithBaseURL(u.String()),
+		WithPassCredentialsAll(entry.PassCredentialsAll),
+	}
+	if entry.CAFile != "" {
+		entryOptions = append(entryOptions, WithCAFile(entry.CAFile))
+	}
+	if (entry.CertFile == "") != (entry.KeyFile == "") {
+		return nil, "", errors.Errorf("helm repository %s needs both certFile and keyFile for a client certificate", name)
+	}
+	if entry.CertFile != "" {
+		entryOptions = append(entryOptions, WithClientCertificate(entry.CertFile, entry.KeyFile))
+	}
+	if entry.InsecureSkipTLSverify {
+		entryOptions = append(entryOptions, WithInsecureSkipTLSVerify(true))
+	}
+	options = append(entryOptions, options...)
+
+	var client *Client
+	if entry.Username != "" {
+		client, err = NewBasicAuthClient(entry.Username, entry.Password, options...)
+	} else {
+		client, err = NewClient(options...)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return client, repo, nil
+}
+
+// loadHelmRepoEntry returns the repository called name of repositoryConfig.
+func loadHelmRepoEntry(repositoryConfig, name string) (*helmRepoEntry, error) {
+	data, err := ioutil.ReadFile(repositoryConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't load repositories file (%s)", repositoryConfig)
+	}
+
+	f := &helmRepoFile{}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, errors.Wrapf(err, "couldn't parse repositories file (%s)", repositoryConfig)
+	}
+
+	for _, entry := range f.Repositories {
+		if entry.Name == name {
+			return entry, nil
+		}
+	}
+	return nil, errors.Errorf("no repository named %q found in %s", name, repositoryConfig)
+}
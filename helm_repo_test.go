@@ -0,0 +1,141 @@
+package chartmuseum
+
+import (
+	"encoding/pem"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewClientFromHelmRepo(t *testing.T) {
+	convey.Convey("从 helm 的 repositories.yaml 读取仓库配置", t, func() {
+		var (
+			username, password string
+			ts                 *httptest.Server
+		)
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, _ = r.BasicAuth()
+			switch r.URL.Path {
+			case "/api/org/team/charts":
+				w.Write([]byte(`{}`))
+			case "/api/org/team/charts/mysql":
+				// Redirect to another host name of the same server.
+				http.Redirect(w, r, strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)+"/api/org/team/charts", http.StatusFound)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+		repositoryConfig := filepath.Join(dir, "repositories.yaml")
+		err = ioutil.WriteFile(repositoryConfig, []byte(`apiVersion: ""
+generated: "2022-10-08T00:00:00Z"
+repositories:
+- name: team
+  url: `+ts.URL+`/org/team
+  username: admin
+  password: password
+  pass_credentials_all: true
+- name: private
+  url: `+ts.URL+`/org/team
+  username: admin
+  password: password
+- name: public
+  url: `+ts.URL+`/
+- name: broken
+  url: `+ts.URL+`/
+  caFile: `+filepath.Join(dir, "missing.crt")+`
+`), 0644)
+		convey.So(err, convey.ShouldBeNil)
+
+		client, repo, err := NewClientFromHelmRepoFile(repositoryConfig, "team")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(repo, convey.ShouldEqual, "org/team")
+		convey.So(client.BaseURL().String(), convey.ShouldEqual, ts.URL+"/")
+		_, _, err = client.Charts.ListCharts(repo)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(username, convey.ShouldEqual, "admin")
+		convey.So(password, convey.ShouldEqual, "password")
+
+		// pass_credentials_all keeps the credentials on the redirect.
+		username = ""
+		_, _, _ = client.Charts.ListVersions(repo, NewChartOption("mysql"))
+		convey.So(username, convey.ShouldEqual, "admin")
+
+		client, _, err = NewClientFromHelmRepoFile(repositoryConfig, "private")
+		convey.So(err, convey.ShouldBeNil)
+		username = "unknown"
+		_, _, _ = client.Charts.ListVersions(repo, NewChartOption("mysql"))
+		convey.So(username, convey.ShouldBeEmpty)
+
+		client, repo, err = NewClientFromHelmRepoFile(repositoryConfig, "public")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(repo, convey.ShouldBeEmpty)
+		convey.So(client.authType, convey.ShouldEqual, DefaultNoAuth)
+
+		_, _, err = NewClientFromHelmRepoFile(repositoryConfig, "broken")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, _, err = NewClientFromHelmRepoFile(repositoryConfig, "unknown")
+		convey.So(err, convey.ShouldNotBeNil)
+
+		os.Setenv(helmRepositoryConfigEnv, repositoryConfig)
+		defer os.Unsetenv(helmRepositoryConfigEnv)
+		_, repo, err = NewClientFromHelmRepo("team")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(repo, convey.ShouldEqual, "org/team")
+	})
+}
+
+func TestNewClientFromHelmRepo_TLS(t *testing.T) {
+	convey.Convey("自定义 Transport 时保留 repositories.yaml 中的 TLS 配置", t, func() {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+		caFile := filepath.Join(dir, "ca.crt")
+		err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+		convey.So(err, convey.ShouldBeNil)
+		repositoryConfig := filepath.Join(dir, "repositories.yaml")
+		err = ioutil.WriteFile(repositoryConfig, []byte(`repositories:
+- name: ca
+  url: `+ts.URL+`/
+  caFile: `+caFile+`
+- name: insecure
+  url: `+ts.URL+`/
+  insecure_skip_tls_verify: true
+- name: cert-only
+  url: `+ts.URL+`/
+  certFile: `+caFile+`
+`), 0644)
+		convey.So(err, convey.ShouldBeNil)
+
+		transport := &http.Transport{}
+		client, _, err := NewClientFromHelmRepoFile(repositoryConfig, "ca", WithTransport(transport), WithoutRetries())
+		convey.So(err, convey.ShouldBeNil)
+		_, err = client.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+
+		httpClient := &http.Client{}
+		client, _, err = NewClientFromHelmRepoFile(repositoryConfig, "insecure", WithHTTPClient(httpClient), WithoutRetries())
+		convey.So(err, convey.ShouldBeNil)
+		_, err = client.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(httpClient.Transport, convey.ShouldBeNil)
+
+		_, _, err = NewClientFromHelmRepoFile(repositoryConfig, "cert-only")
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "cert-only")
+	})
+}
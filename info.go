@@ -19,6 +19,7 @@ type InfoService struct {
 
 func (s *InfoService) Index(options ...RequestOptionFunc) (string, error) {
 	u := "/"
+	options = appendOptions(options, withOperation("Index", "", "", ""))
 	req, err := s.client.NewRequest(http.MethodGet, u, nil, options)
 	if err != nil {
 		return "", err
@@ -33,6 +34,7 @@ func (s *InfoService) Index(options ...RequestOptionFunc) (string, error) {
 
 func (s *InfoService) Health(options ...RequestOptionFunc) (*Healthy, error) {
 	u := "/health"
+	options = appendOptions(options, withOperation("Health", "", "", ""))
 	req, err := s.client.NewRequest(http.MethodGet, u, nil, options)
 	if err != nil {
 		return nil, err
@@ -47,6 +49,7 @@ func (s *InfoService) Health(options ...RequestOptionFunc) (*Healthy, error) {
 
 func (s *InfoService) Info(options ...RequestOptionFunc) (*Version, error) {
 	u := "/info"
+	options = appendOptions(options, withOperation("Info", "", "", ""))
 	req, err := s.client.NewRequest(http.MethodGet, u, nil, options)
 	if err != nil {
 		return nil, err
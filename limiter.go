@@ -0,0 +1,117 @@
+package chartmuseum
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRateLimitWindow is the window of the RateLimit-Limit header when the
+// server does not announce one with a "w" parameter.
+const defaultRateLimitWindow = time.Minute
+
+// limiterProbeTimeout bounds the request which reads the RateLimit-Limit
+// header, which does not use the context of any API request.
+const limiterProbeTimeout = 10 * time.Second
+
+// RateLimiter describes the interface that all (custom) limiters need to implement.
+type RateLimiter interface {
+	Wait(context.Context) error
+}
+
+// configureLimiter configures the rate limiter. Unless the client was asked
+// to size the limiter from the RateLimit-Limit header, the limiter set by the
+// client options is kept, or requests are not limited at all.
+//
+// The header is read with a context of its own, so that a canceled API
+// request which happens to run first doesn't disable it for good.
+func (c *Client) configureLimiter() {
+	if c.limiter == nil {
+		// Set default values for when rate limiting is disabled.
+		c.limiter = rate.NewLimiter(rate.Inf, 0)
+	}
+	if !c.limiterFromHeader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), limiterProbeTimeout)
+	defer cancel()
+
+	// Create a new request.
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.baseURL.String(), nil)
+	if err != nil {
+		return
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	// Make a single request to retrieve the rate limit headers.
+	resp, err := c.client.HTTPClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	limit, window := parseRateLimit(resp.Header.Get(headerRateLimit))
+	if limit <= 0 {
+		return
+	}
+
+	// The rate limit is based on requests per window, so for our limiter to
+	// work correctly we divide the limit by the window to get the limit per
+	// second.
+	perSecond := limit / window.Seconds()
+
+	// Configure the limit and burst using a split of 2/3 for the limit and
+	// 1/3 for the burst. This enables clients to burst 1/3 of the allowed
+	// calls before the limiter kicks in. The remaining calls will then be
+	// spread out evenly using intervals of time.Second / limit which should
+	// prevent hitting the rate limit.
+	burst := int(perSecond * 0.33)
+	// Need at least one allowed to burst or x/time will throw an error.
+	if burst == 0 {
+		burst = 1
+	}
+	limiter := rate.NewLimiter(rate.Limit(perSecond*0.66), burst)
+
+	// Call the limiter once as we have already made a request to get the
+	// headers and the limiter is not aware of this.
+	limiter.Wait(ctx)
+
+	c.limiter = limiter
+}
+
+// parseRateLimit parses a RateLimit-Limit header such as "100" or
+// "100, 100;w=60" into the number of allowed requests and their window.
+func parseRateLimit(v string) (float64, time.Duration) {
+	window := defaultRateLimitWindow
+
+	parts := strings.Split(v, ",")
+	limit, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, window
+	}
+
+	// Look for the window of the quota policy matching the limit.
+	for _, policy := range parts[1:] {
+		params := strings.Split(policy, ";")
+		if strings.TrimSpace(params[0]) != strings.TrimSpace(parts[0]) {
+			continue
+		}
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || kv[0] != "w" {
+				continue
+			}
+			if w, err := strconv.Atoi(kv[1]); err == nil && w > 0 {
+				window = time.Duration(w) * time.Second
+			}
+		}
+	}
+
+	return limit, window
+}
@@ -0,0 +1,90 @@
+package chartmuseum
+
+import (
+	"context"
+	"github.com/smartystreets/goconvey/convey"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	convey.Convey("解析 RateLimit-Limit 头", t, func() {
+		testCases := []struct {
+			input          string
+			expectedLimit  float64
+			expectedWindow time.Duration
+		}{
+			{input: "600", expectedLimit: 600, expectedWindow: time.Minute},
+			{input: "10, 10;w=1", expectedLimit: 10, expectedWindow: time.Second},
+			{input: "100, 50;w=1, 100;w=3600", expectedLimit: 100, expectedWindow: time.Hour},
+			{input: "", expectedLimit: 0, expectedWindow: time.Minute},
+			{input: "abc", expectedLimit: 0, expectedWindow: time.Minute},
+		}
+		for _, tc := range testCases {
+			limit, window := parseRateLimit(tc.input)
+			convey.So(limit, convey.ShouldEqual, tc.expectedLimit)
+			convey.So(window, convey.ShouldEqual, tc.expectedWindow)
+		}
+	})
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	convey.Convey("客户端限流", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerRateLimit, "600")
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+
+		convey.Convey("默认不限流", func() {
+			client, _ := NewClient(WithBaseURL(ts.URL))
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(client.limiter.(*rate.Limiter).Limit(), convey.ShouldEqual, rate.Inf)
+		})
+
+		convey.Convey("固定速率的令牌桶", func() {
+			client, _ := NewClient(WithBaseURL(ts.URL), WithRateLimit(20, 1))
+			start := time.Now()
+			for i := 0; i < 3; i++ {
+				_, err := client.Info.Health()
+				convey.So(err, convey.ShouldBeNil)
+			}
+			convey.So(time.Since(start), convey.ShouldBeGreaterThanOrEqualTo, 90*time.Millisecond)
+		})
+
+		convey.Convey("根据 RateLimit-Limit 头设置速率", func() {
+			client, _ := NewClient(WithBaseURL(ts.URL), WithRateLimitFromHeader())
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			limiter := client.limiter.(*rate.Limiter)
+			convey.So(float64(limiter.Limit()), convey.ShouldAlmostEqual, 6.6, 0.001)
+			convey.So(limiter.Burst(), convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("第一个请求的 context 已取消时仍读取 RateLimit-Limit 头", func() {
+			client, _ := NewClient(WithBaseURL(ts.URL), WithRateLimitFromHeader())
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := client.Info.Health(WithContext(ctx))
+			convey.So(err, convey.ShouldNotBeNil)
+
+			_, err = client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			limiter := client.limiter.(*rate.Limiter)
+			convey.So(float64(limiter.Limit()), convey.ShouldAlmostEqual, 6.6, 0.001)
+		})
+
+		convey.Convey("非法的限流参数", func() {
+			_, err := NewClient(WithRateLimit(5, 0))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = NewClient(WithRateLimit(0, 1))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = NewClient(WithRateLimit(-1, 1))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
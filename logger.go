@@ -0,0 +1,96 @@
+package chartmuseum
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// Logger is used by the client to log its requests at debug level. The
+// keysAndValues are alternating keys and values, the same convention as
+// log/slog, so a *slog.Logger can be used as is.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+}
+
+// LoggerFunc adapts a function to Logger, e.g. the Debugw method of a zap
+// SugaredLogger.
+type LoggerFunc func(msg string, keysAndValues ...interface{})
+
+// Debug implements Logger.
+func (f LoggerFunc) Debug(msg string, keysAndValues ...interface{}) {
+	f(msg, keysAndValues...)
+}
+
+// NewLogrusLogger adapts a logrus logger to Logger, the keys and values are
+// logged as fields.
+func NewLogrusLogger(logger logrus.FieldLogger) Logger {
+	return &logrusLogger{logger: logger}
+}
+
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l *logrusLogger) Debug(msg string, keysAndValues ...interface{}) {
+	fields := logrus.Fields{}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+	l.logger.WithFields(fields).Debug(msg)
+}
+
+// WithLogger routes the logs of the client to logger, e.g. one made by
+// NewLogrusLogger. The client doesn't log by default, a nil logger disables
+// logging again.
+func WithLogger(logger Logger) ClientOptionFunc {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// loggingMiddleware logs every API call with its status and duration.
+func (c *Client) loggingMiddleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *retryablehttp.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		duration := time.Since(start)
+
+		if err != nil {
+			c.logger.Debug("request failed",
+				"method", req.Method,
+				"url", req.URL.Redacted(),
+				"duration", duration,
+				"error", err,
+			)
+			return resp, err
+		}
+		c.logger.Debug("request done",
+			"method", req.Method,
+			"url", req.URL.Redacted(),
+			"status", resp.StatusCode,
+			"duration", duration,
+		)
+		return resp, err
+	}
+}
+
+// logAttempt logs the retries of a request.
+func (c *Client) logAttempt(req *http.Request, attempt int) {
+	if attempt == 0 {
+		return
+	}
+	c.logger.Debug("retrying request",
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"attempt", attempt,
+	)
+}
@@ -0,0 +1,87 @@
+package chartmuseum
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Logger(t *testing.T) {
+	convey.Convey("可插拔的日志", t, func() {
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+		baseURL := strings.Replace(ts.URL, "http://", "http://admin:secret@", 1)
+		fastRetry := WithCustomRetryWaitMinMax(time.Millisecond, 2*time.Millisecond)
+
+		convey.Convey("自定义 Logger", func() {
+			var logs []string
+			logger := LoggerFunc(func(msg string, keysAndValues ...interface{}) {
+				logs = append(logs, fmt.Sprintln(append([]interface{}{msg}, keysAndValues...)...))
+			})
+			client, _ := NewClient(WithBaseURL(baseURL), fastRetry, WithLogger(logger))
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+
+			convey.So(logs, convey.ShouldHaveLength, 2)
+			convey.So(logs[0], convey.ShouldStartWith, "retrying request")
+			convey.So(logs[0], convey.ShouldContainSubstring, "attempt 1")
+			convey.So(logs[1], convey.ShouldStartWith, "request done")
+			convey.So(logs[1], convey.ShouldContainSubstring, "status 200")
+			convey.So(logs[1], convey.ShouldContainSubstring, "duration")
+			for _, log := range logs {
+				convey.So(log, convey.ShouldNotContainSubstring, "secret")
+			}
+		})
+
+		convey.Convey("logrus 适配器", func() {
+			buf := new(bytes.Buffer)
+			logger := logrus.New()
+			logger.SetOutput(buf)
+			logger.SetLevel(logrus.DebugLevel)
+			client, _ := NewClient(WithBaseURL(baseURL), fastRetry, WithLogger(NewLogrusLogger(logger)))
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(buf.String(), convey.ShouldContainSubstring, `msg="request done"`)
+			convey.So(buf.String(), convey.ShouldContainSubstring, "method=GET")
+			convey.So(buf.String(), convey.ShouldContainSubstring, "status=200")
+			convey.So(buf.String(), convey.ShouldNotContainSubstring, "secret")
+		})
+
+		convey.Convey("默认不写入全局 logrus logger", func() {
+			buf := new(bytes.Buffer)
+			std := logrus.StandardLogger()
+			out, level := std.Out, std.GetLevel()
+			std.SetOutput(buf)
+			std.SetLevel(logrus.DebugLevel)
+			defer func() {
+				std.SetOutput(out)
+				std.SetLevel(level)
+			}()
+
+			client, _ := NewClient(WithBaseURL(baseURL), fastRetry)
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(buf.String(), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("关闭日志", func() {
+			client, _ := NewClient(WithBaseURL(baseURL), fastRetry, WithLogger(nil))
+			_, err := client.Info.Health()
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+}
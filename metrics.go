@@ -0,0 +1,136 @@
+package chartmuseum
+
+import (
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics is a prometheus.Collector recording the API calls of the clients
+// it is attached to with WithMetrics. Register it once with a registry, it
+// can be shared by several clients.
+type Metrics struct {
+	requests        *prometheus.CounterVec
+	duration        *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+	uploadedBytes   *prometheus.CounterVec
+	downloadedBytes *prometheus.CounterVec
+}
+
+// NewMetrics returns a new Metrics collector whose metric names start with
+// namespace, e.g. "chartmuseum_client".
+func NewMetrics(namespace string) *Metrics {
+	labels := []string{"operation"}
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of API calls by operation, method and status code.",
+		}, []string{"operation", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of API calls including retries by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Number of retried requests by operation.",
+		}, labels),
+		uploadedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "uploaded_bytes_total",
+			Help:      "Number of bytes sent in request bodies by operation.",
+		}, labels),
+		downloadedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "downloaded_bytes_total",
+			Help:      "Number of bytes read from response bodies by operation.",
+		}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.retries.Describe(ch)
+	m.uploadedBytes.Describe(ch)
+	m.downloadedBytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.retries.Collect(ch)
+	m.uploadedBytes.Collect(ch)
+	m.downloadedBytes.Collect(ch)
+}
+
+// WithMetrics records the API calls of the client with m.
+func WithMetrics(m *Metrics) ClientOptionFunc {
+	return func(c *Client) error {
+		c.middlewares = append(c.middlewares, m.middleware)
+		c.attemptHooks = append(c.attemptHooks, m.attemptHook)
+		return nil
+	}
+}
+
+// middleware records the number, latency and downloaded bytes of API calls.
+func (m *Metrics) middleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *retryablehttp.Request) (*http.Response, error) {
+		op := operationName(req.Request)
+
+		start := time.Now()
+		resp, err := next(req)
+		m.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+			resp.Body = &countingReadCloser{ReadCloser: resp.Body, counter: m.downloadedBytes.WithLabelValues(op)}
+		}
+		m.requests.WithLabelValues(op, req.Method, code).Inc()
+		return resp, err
+	}
+}
+
+// attemptHook records retries and the bytes sent by every attempt. The body
+// is counted as it is read, so that uploads of unknown size are recorded too.
+func (m *Metrics) attemptHook(req *http.Request, attempt int) {
+	op := operationName(req)
+	if attempt > 0 {
+		m.retries.WithLabelValues(op).Inc()
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &countingReadCloser{ReadCloser: req.Body, counter: m.uploadedBytes.WithLabelValues(op)}
+	}
+}
+
+// operationName returns the name of the operation of req, or "other" for
+// requests not sent by ChartService or InfoService.
+func operationName(req *http.Request) string {
+	if op, ok := operationFromContext(req.Context()); ok {
+		return op.Name
+	}
+	return "other"
+}
+
+// countingReadCloser adds the number of bytes read to counter.
+type countingReadCloser struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.counter.Add(float64(n))
+	}
+	return n, err
+}
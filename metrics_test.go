@@ -0,0 +1,80 @@
+package chartmuseum
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Metrics(t *testing.T) {
+	convey.Convey("Prometheus 指标", t, func() {
+		chart, _ := ioutil.ReadFile("./testdata/chart-demo-0.1.0.tgz")
+		var lock sync.Mutex
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			attempts++
+			first := attempts == 1
+			lock.Unlock()
+			switch {
+			case first:
+				w.WriteHeader(http.StatusBadGateway)
+			case r.Method == http.MethodPost:
+				ioutil.ReadAll(r.Body)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"saved":true}`))
+			case r.URL.Path == "/test/charts/chart-demo-0.1.0.tgz":
+				w.Write(chart)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
+		metrics := NewMetrics("chartmuseum_client")
+		registry := prometheus.NewPedanticRegistry()
+		convey.So(registry.Register(metrics), convey.ShouldBeNil)
+
+		client, err := NewClient(
+			WithBaseURL(ts.URL),
+			WithCustomRetryWaitMinMax(time.Millisecond, 2*time.Millisecond),
+			WithMetrics(metrics),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		_, err = client.Charts.UploadChart(testRepo, "./testdata/chart-demo-0.1.0.tgz")
+		convey.So(err, convey.ShouldBeNil)
+		_, err = client.Charts.DownloadChartTo(testRepo, ioutil.Discard, NewChartVersionOption("chart-demo", "0.1.0"))
+		convey.So(err, convey.ShouldBeNil)
+		exists, _, err := client.Charts.IsExist(testRepo, NewChartOption("mysql"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(exists, convey.ShouldBeFalse)
+
+		convey.So(testutil.ToFloat64(metrics.requests.WithLabelValues("UploadChart", http.MethodPost, "201")), convey.ShouldEqual, 1)
+		convey.So(testutil.ToFloat64(metrics.requests.WithLabelValues("DownloadChartTo", http.MethodGet, "200")), convey.ShouldEqual, 1)
+		convey.So(testutil.ToFloat64(metrics.requests.WithLabelValues("IsExist", http.MethodHead, "404")), convey.ShouldEqual, 1)
+		convey.So(testutil.ToFloat64(metrics.retries.WithLabelValues("UploadChart")), convey.ShouldEqual, 1)
+		// The upload was sent twice because of the retry.
+		convey.So(testutil.ToFloat64(metrics.uploadedBytes.WithLabelValues("UploadChart")), convey.ShouldEqual, 2*len(chart))
+		convey.So(testutil.ToFloat64(metrics.downloadedBytes.WithLabelValues("DownloadChartTo")), convey.ShouldEqual, len(chart))
+
+		// A file of unknown size is sent chunked, its bytes are counted as read.
+		file, err := os.Open("./testdata/chart-demo-0.1.0.tgz")
+		convey.So(err, convey.ShouldBeNil)
+		defer file.Close()
+		_, err = client.Charts.UploadChartReader(testRepo, file, -1)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(testutil.ToFloat64(metrics.uploadedBytes.WithLabelValues("UploadChartReader")), convey.ShouldEqual, len(chart))
+
+		count, err := testutil.GatherAndCount(registry, "chartmuseum_client_request_duration_seconds")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(count, convey.ShouldEqual, 4)
+	})
+}
@@ -0,0 +1,41 @@
+package chartmuseum
+
+import (
+	"github.com/hashicorp/go-retryablehttp"
+	"net/http"
+)
+
+// RoundTripFunc sends an API request, including all its retries, and returns
+// the raw response.
+type RoundTripFunc func(req *retryablehttp.Request) (*http.Response, error)
+
+// Middleware wraps the RoundTripFunc of the client to observe or modify all
+// requests and responses, e.g. to inject headers, log, measure latency or
+// sign requests. Middlewares are called after the authentication header has
+// been set.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// WithMiddleware adds middlewares to the client. The first middleware is the
+// outermost one, so it sees the request first and the response last.
+func WithMiddleware(middlewares ...Middleware) ClientOptionFunc {
+	return func(c *Client) error {
+		c.middlewares = append(c.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// buildRoundTrip chains the middlewares around the retrying HTTP client.
+// Logging, if enabled, is done closest to the HTTP client.
+func (c *Client) buildRoundTrip() {
+	c.roundTrip = c.client.Do
+	if c.logger != nil {
+		c.roundTrip = c.loggingMiddleware(c.roundTrip)
+		c.attemptHooks = append(c.attemptHooks, c.logAttempt)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		if c.middlewares[i] == nil {
+			continue
+		}
+		c.roundTrip = c.middlewares[i](c.roundTrip)
+	}
+}
@@ -0,0 +1,61 @@
+package chartmuseum
+
+import (
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Middleware(t *testing.T) {
+	convey.Convey("客户端中间件", t, func() {
+		var header, authorization string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("X-Request-Id")
+			authorization = r.Header.Get("Authorization")
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+
+		var calls []string
+		var status int
+		var latency time.Duration
+		tracer := func(name string) Middleware {
+			return func(next RoundTripFunc) RoundTripFunc {
+				return func(req *retryablehttp.Request) (*http.Response, error) {
+					calls = append(calls, name+" before")
+					resp, err := next(req)
+					calls = append(calls, name+" after")
+					return resp, err
+				}
+			}
+		}
+		requestID := func(next RoundTripFunc) RoundTripFunc {
+			return func(req *retryablehttp.Request) (*http.Response, error) {
+				// The authentication header is already set.
+				req.Header.Set("X-Request-Id", req.Header.Get("Authorization")+"-1")
+				start := time.Now()
+				resp, err := next(req)
+				latency = time.Since(start)
+				if resp != nil {
+					status = resp.StatusCode
+				}
+				return resp, err
+			}
+		}
+
+		client, err := NewTokenClient("token", WithBaseURL(ts.URL), WithMiddleware(tracer("outer"), nil, tracer("inner")), WithMiddleware(requestID))
+		convey.So(err, convey.ShouldBeNil)
+		health, err := client.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(health.Healthy, convey.ShouldBeTrue)
+
+		convey.So(calls, convey.ShouldResemble, []string{"outer before", "inner before", "inner after", "outer after"})
+		convey.So(authorization, convey.ShouldEqual, "Bearer token")
+		convey.So(header, convey.ShouldEqual, "Bearer token-1")
+		convey.So(status, convey.ShouldEqual, http.StatusOK)
+		convey.So(latency, convey.ShouldBeGreaterThan, 0)
+	})
+}
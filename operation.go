@@ -0,0 +1,45 @@
+package chartmuseum
+
+import (
+	"context"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// operation describes the ChartService or InfoService call a request is sent
+// for. It is attached to the context of the request, so that tracing,
+// metrics and logging can tell the calls apart.
+type operation struct {
+	Name    string
+	Repo    string
+	Chart   string
+	Version string
+}
+
+type operationKey struct{}
+
+// withOperation attaches the operation to the request. If the request
+// already belongs to an operation, e.g. GetVersion called by
+// DownloadVerifiedChart, the outer operation is kept.
+func withOperation(name, repo, chart, version string) RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		if _, ok := operationFromContext(req.Context()); ok {
+			return nil
+		}
+		op := operation{Name: name, Repo: repo, Chart: chart, Version: version}
+		*req = *req.WithContext(context.WithValue(req.Context(), operationKey{}, op))
+		return nil
+	}
+}
+
+// appendOptions returns options followed by opts in a new slice, so that the
+// slice passed by the caller is never written to, even if it has spare
+// capacity and is shared between goroutines.
+func appendOptions(options []RequestOptionFunc, opts ...RequestOptionFunc) []RequestOptionFunc {
+	return append(append(make([]RequestOptionFunc, 0, len(options)+len(opts)), options...), opts...)
+}
+
+// operationFromContext returns the operation attached by withOperation.
+func operationFromContext(ctx context.Context) (operation, bool) {
+	op, ok := ctx.Value(operationKey{}).(operation)
+	return op, ok
+}
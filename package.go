@@ -0,0 +1,65 @@
+package chartmuseum
+
+import (
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"io/ioutil"
+	"os"
+)
+
+// PackageOption customizes the chart packaged by PackageAndUpload.
+type PackageOption struct {
+	// Version overrides the version of Chart.yaml.
+	Version *string
+	// AppVersion overrides the appVersion of Chart.yaml.
+	AppVersion *string
+}
+
+// PackageAndUpload loads the unpacked chart in chartDir, packages it into a
+// temporary directory the same way as `helm package` does and uploads the
+// archive to repo.
+func (c *ChartService) PackageAndUpload(repo, chartDir string, opts PackageOption, options ...RequestOptionFunc) (*Response, error) {
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := loader.LoadDir(chartDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load chart from %s", chartDir)
+	}
+
+	if opts.Version != nil {
+		if _, err := semver.NewVersion(*opts.Version); err != nil {
+			return nil, errors.Wrapf(err, "invalid chart version %q", *opts.Version)
+		}
+		ch.Metadata.Version = *opts.Version
+	}
+	if opts.AppVersion != nil {
+		ch.Metadata.AppVersion = *opts.AppVersion
+	}
+	options = appendOptions(options, withOperation("PackageAndUpload", repoUrl, ch.Metadata.Name, ch.Metadata.Version))
+
+	dir, err := ioutil.TempDir("", "chartmuseum-package")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	archive, err := chartutil.Save(ch, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to package chart %s", ch.Name())
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return c.UploadChartReader(repo, f, stat.Size(), options...)
+}
@@ -0,0 +1,69 @@
+package chartmuseum
+
+import (
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/smartystreets/goconvey/convey"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChartService_PackageAndUpload(t *testing.T) {
+	convey.Convey("打包 chart 目录并上传", t, func() {
+		var uploaded *chart.Chart
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/api/test/charts" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			ch, err := loader.LoadArchive(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"` + err.Error() + `"}`))
+				return
+			}
+			uploaded = ch
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"saved":true}`))
+		}))
+		defer ts.Close()
+
+		dir, err := ioutil.TempDir("", "chartmuseum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+		source, err := loader.Load("./testdata/chart-demo-0.1.0.tgz")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(chartutil.SaveDir(source, dir), convey.ShouldBeNil)
+		chartDir := filepath.Join(dir, source.Name())
+
+		var op operation
+		client, _ := NewClient(WithBaseURL(ts.URL), WithMiddleware(func(next RoundTripFunc) RoundTripFunc {
+			return func(req *retryablehttp.Request) (*http.Response, error) {
+				op, _ = operationFromContext(req.Context())
+				return next(req)
+			}
+		}))
+		resp, err := client.Charts.PackageAndUpload(testRepo, chartDir, PackageOption{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		convey.So(uploaded.Metadata.Version, convey.ShouldEqual, source.Metadata.Version)
+		convey.So(uploaded.Templates, convey.ShouldHaveLength, len(source.Templates))
+
+		version, appVersion := "0.2.0-SNAPSHOT", "2.0.0"
+		_, err = client.Charts.PackageAndUpload("/"+testRepo+"/", chartDir, PackageOption{Version: &version, AppVersion: &appVersion})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(op, convey.ShouldResemble, operation{Name: "PackageAndUpload", Repo: testRepo, Chart: "chart-demo", Version: version})
+		convey.So(uploaded.Metadata.Version, convey.ShouldEqual, version)
+		convey.So(uploaded.Metadata.AppVersion, convey.ShouldEqual, appVersion)
+
+		invalid := "not-a-version"
+		_, err = client.Charts.PackageAndUpload(testRepo, chartDir, PackageOption{Version: &invalid})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -16,11 +16,27 @@ func WithContext(ctx context.Context) RequestOptionFunc {
 	}
 }
 
+// WithUpload sets the content type and length of an upload. A negative size
+// keeps the length computed from the request body.
 func WithUpload(mediaType string, size int64) RequestOptionFunc {
 	return func(req *retryablehttp.Request) error {
 		req.Header.Set("Content-Type", mediaType)
 
-		req.ContentLength = size
+		if size >= 0 {
+			req.ContentLength = size
+		}
+		return nil
+	}
+}
+
+// WithForceOverwrite asks the server to replace an already existing chart
+// version on upload. ChartMuseum rejects the upload with 409 Conflict if it
+// runs with --disable-force-overwrite.
+func WithForceOverwrite() RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		q := req.URL.Query()
+		q.Set("force", "true")
+		req.URL.RawQuery = q.Encode()
 		return nil
 	}
 }
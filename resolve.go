@@ -0,0 +1,72 @@
+package chartmuseum
+
+import (
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	helmrepo "helm.sh/helm/v3/pkg/repo"
+)
+
+// ResolveVersion returns the highest version of the chart which satisfies the
+// semver constraint, e.g. "^1.2", "~2.3.x" or ">=1.0 <2.0". An empty
+// constraint matches every version.
+//
+// Prereleases only match a constraint which itself contains a prerelease,
+// unless includePrereleases is set: then a prerelease also matches when the
+// release it precedes does, so "^1.2" matches "1.3.0-rc.1". Versions which
+// are not valid semver are skipped. If no version matches, an error wrapping
+// ErrNotFound is returned.
+func (c *ChartService) ResolveVersion(repo string, chart ChartOption, constraint string, includePrereleases bool, options ...RequestOptionFunc) (*helmrepo.ChartVersion, *Response, error) {
+	if constraint == "" {
+		constraint = "*"
+	}
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid version constraint %q", constraint)
+	}
+
+	repoUrl, err := parseRepoUrl(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	options = appendOptions(options, withOperation("ResolveVersion", repoUrl, *chart.Name, ""))
+
+	versions, resp, err := c.ListVersions(repo, chart, options...)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var (
+		best    *helmrepo.ChartVersion
+		bestVer *semver.Version
+	)
+	for _, cv := range *versions {
+		v, err := semver.NewVersion(cv.Version)
+		if err != nil {
+			continue
+		}
+		if !matchVersion(constraints, v, includePrereleases) {
+			continue
+		}
+		if bestVer == nil || v.GreaterThan(bestVer) {
+			best, bestVer = cv, v
+		}
+	}
+	if best == nil {
+		return nil, resp, errors.Wrapf(ErrNotFound, "no version of chart %s matches %q", *chart.Name, constraint)
+	}
+	return best, resp, nil
+}
+
+func matchVersion(constraints *semver.Constraints, v *semver.Version, includePrereleases bool) bool {
+	if constraints.Check(v) {
+		return true
+	}
+	if !includePrereleases || v.Prerelease() == "" {
+		return false
+	}
+	release, err := v.SetPrerelease("")
+	if err != nil {
+		return false
+	}
+	return constraints.Check(&release)
+}
@@ -0,0 +1,62 @@
+package chartmuseum
+
+import (
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChartService_ResolveVersion(t *testing.T) {
+	convey.Convey("按 semver 约束解析版本", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/test/charts/mysql" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`[
+				{"name":"mysql","version":"1.0.0-rc.9"},
+				{"name":"mysql","version":"1.0.0-rc.10"},
+				{"name":"mysql","version":"1.0.0"},
+				{"name":"mysql","version":"1.2.0"},
+				{"name":"mysql","version":"1.3.0-rc.1"},
+				{"name":"mysql","version":"2.3.4"},
+				{"name":"mysql","version":"2.4.0"},
+				{"name":"mysql","version":"not-semver"}
+			]`))
+		}))
+		defer ts.Close()
+
+		client, _ := NewClient(WithBaseURL(ts.URL))
+		testCases := []struct {
+			constraint         string
+			includePrereleases bool
+			expected           string
+			expectedErr        bool
+		}{
+			{constraint: "", expected: "2.4.0"},
+			{constraint: "^1.2", expected: "1.2.0"},
+			{constraint: "^1.2", includePrereleases: true, expected: "1.3.0-rc.1"},
+			{constraint: "~2.3.x", expected: "2.3.4"},
+			{constraint: ">=1.0 <2.0", expected: "1.2.0"},
+			{constraint: "1.0.0-rc.1 - 1.0.0-rc.99", expected: "1.0.0-rc.10"},
+			{constraint: ">=3.0", expectedErr: true},
+			{constraint: "not a constraint", expectedErr: true},
+		}
+		for _, tc := range testCases {
+			cv, resp, err := client.Charts.ResolveVersion(testRepo, NewChartOption("mysql"), tc.constraint, tc.includePrereleases)
+			convey.So(err != nil, convey.ShouldEqual, tc.expectedErr)
+			if tc.expectedErr {
+				continue
+			}
+			convey.So(resp, convey.ShouldNotBeNil)
+			convey.So(cv.Version, convey.ShouldEqual, tc.expected)
+		}
+
+		_, _, err := client.Charts.ResolveVersion(testRepo, NewChartOption("mysql"), ">=3.0", false)
+		convey.So(IsNotFound(err), convey.ShouldBeTrue)
+
+		_, err = client.Charts.GetLatestChartVersionWithRegex(testRepo, NewChartOption("mysql"), "[")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,62 @@
+package chartmuseum
+
+import (
+	"context"
+	"sync"
+)
+
+// TokenSource supplies the bearer tokens used to authenticate requests. The
+// client consults it before each request, and when the server answers with
+// 401 Unauthorized the rejected token is invalidated and the request is
+// retried once with a new token.
+type TokenSource interface {
+	// Token returns the token to use for the next request.
+	Token(ctx context.Context) (string, error)
+
+	// Invalidate tells the source that token was rejected by the server, so
+	// the next call to Token must return a new one.
+	Invalidate(token string)
+}
+
+// TokenFunc fetches a new token, for example from a JWT issuer.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// NewCachedTokenSource returns a TokenSource which calls fetch once and
+// reuses the returned token until it is invalidated.
+func NewCachedTokenSource(fetch TokenFunc) TokenSource {
+	return &cachedTokenSource{fetch: fetch}
+}
+
+type cachedTokenSource struct {
+	fetch TokenFunc
+
+	lock  sync.Mutex
+	token string
+}
+
+func (s *cachedTokenSource) Token(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" {
+		return s.token, nil
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	return token, nil
+}
+
+func (s *cachedTokenSource) Invalidate(token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Only drop the cached token if it has not been refreshed in the meantime
+	// by another request.
+	if s.token == token {
+		s.token = ""
+	}
+}
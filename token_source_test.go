@@ -0,0 +1,67 @@
+package chartmuseum
+
+import (
+	"context"
+	"fmt"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_TokenSource(t *testing.T) {
+	convey.Convey("token 过期后自动刷新并重试", t, func() {
+		valid := "token-1"
+		var uploads [][]byte
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+valid {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"unauthorized"}`))
+				return
+			}
+			if r.Method == http.MethodPost {
+				body, _ := ioutil.ReadAll(r.Body)
+				uploads = append(uploads, body)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"saved":true}`))
+				return
+			}
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+
+		fetched := 0
+		source := NewCachedTokenSource(func(ctx context.Context) (string, error) {
+			fetched++
+			return fmt.Sprintf("token-%d", fetched), nil
+		})
+		client, err := NewClient(WithBaseURL(ts.URL), WithTokenSource(source))
+		convey.So(err, convey.ShouldBeNil)
+
+		_, err = client.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+		_, err = client.Info.Health()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(fetched, convey.ShouldEqual, 1)
+
+		// The issuer rotates the token, the client must refresh it and retry
+		// the upload with the full body.
+		valid = "token-2"
+		chartPath := "./testdata/chart-demo-0.1.0.tgz"
+		chart, _ := ioutil.ReadFile(chartPath)
+		resp, err := client.Charts.UploadChart(testRepo, chartPath)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+		convey.So(fetched, convey.ShouldEqual, 2)
+		convey.So(client.Token(), convey.ShouldEqual, "token-2")
+		convey.So(uploads, convey.ShouldHaveLength, 1)
+		convey.So(uploads[0], convey.ShouldResemble, chart)
+
+		// Only one retry is done when the new token is rejected as well.
+		valid = "token-invalid"
+		_, err = client.Info.Index()
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(fetched, convey.ShouldEqual, 3)
+	})
+}
@@ -0,0 +1,91 @@
+package chartmuseum
+
+import (
+	"github.com/hashicorp/go-retryablehttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const tracerName = "github.com/yidaqiang/go-chartmuseum"
+
+// Attributes recorded on the spans of API calls in addition to the HTTP
+// semantic conventions.
+const (
+	repoKey         = attribute.Key("chartmuseum.repo")
+	chartKey        = attribute.Key("chartmuseum.chart")
+	versionKey      = attribute.Key("chartmuseum.version")
+	retryAttemptKey = attribute.Key("chartmuseum.retry_attempt")
+)
+
+// WithTracing records an OpenTelemetry span for every API call, created by
+// tp, and propagates the trace context to the server using propagator. The
+// global tracer provider and the W3C trace context are used if nil.
+func WithTracing(tp trace.TracerProvider, propagator propagation.TextMapPropagator) ClientOptionFunc {
+	return func(c *Client) error {
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+		if propagator == nil {
+			propagator = propagation.TraceContext{}
+		}
+		tracer := tp.Tracer(tracerName)
+
+		c.middlewares = append(c.middlewares, tracingMiddleware(tracer, propagator))
+		c.attemptHooks = append(c.attemptHooks, func(req *http.Request, attempt int) {
+			span := trace.SpanFromContext(req.Context())
+			span.SetAttributes(retryAttemptKey.Int(attempt))
+			if attempt > 0 {
+				span.AddEvent("retry", trace.WithAttributes(retryAttemptKey.Int(attempt)))
+			}
+		})
+		return nil
+	}
+}
+
+// tracingMiddleware starts a client span around each API call.
+func tracingMiddleware(tracer trace.Tracer, propagator propagation.TextMapPropagator) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *retryablehttp.Request) (*http.Response, error) {
+			name := "chartmuseum " + req.Method
+			attrs := []attribute.KeyValue{
+				semconv.HTTPMethodKey.String(req.Method),
+				semconv.HTTPURLKey.String(req.URL.Redacted()),
+			}
+			if op, ok := operationFromContext(req.Context()); ok {
+				name = "chartmuseum." + op.Name
+				attrs = append(attrs, repoKey.String(op.Repo))
+				if op.Chart != "" {
+					attrs = append(attrs, chartKey.String(op.Chart))
+				}
+				if op.Version != "" {
+					attrs = append(attrs, versionKey.String(op.Version))
+				}
+			}
+
+			ctx, span := tracer.Start(req.Context(), name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			req = req.WithContext(ctx)
+			propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+			resp, err := next(req)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return resp, err
+			}
+
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package chartmuseum
+
+import (
+	"github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spanAttributes returns the attributes of span as a map.
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestClient_Tracing(t *testing.T) {
+	convey.Convey("OpenTelemetry 链路追踪", t, func() {
+		var (
+			lock        sync.Mutex
+			traceparent string
+		)
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			traceparent = r.Header.Get("traceparent")
+			attempts++
+			first := attempts == 1
+			lock.Unlock()
+			if first {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.URL.Path == "/api/test/charts/mysql/0.1.0" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"not found"}`))
+				return
+			}
+			w.Write([]byte(`{"name":"mysql","version":"9.3.4"}`))
+		}))
+		defer ts.Close()
+
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		client, err := NewClient(
+			WithBaseURL(ts.URL),
+			WithCustomRetryWaitMinMax(time.Millisecond, 2*time.Millisecond),
+			WithTracing(tp, nil),
+		)
+		convey.So(err, convey.ShouldBeNil)
+
+		_, _, err = client.Charts.GetVersion(testRepo, NewChartVersionOption("mysql", "9.3.4"))
+		convey.So(err, convey.ShouldBeNil)
+		_, _, err = client.Charts.GetVersion(testRepo, NewChartVersionOption("mysql", "0.1.0"))
+		convey.So(IsNotFound(err), convey.ShouldBeTrue)
+
+		spans := exporter.GetSpans()
+		convey.So(spans, convey.ShouldHaveLength, 2)
+
+		span := spans[0]
+		convey.So(span.Name, convey.ShouldEqual, "chartmuseum.GetVersion")
+		convey.So(span.SpanKind, convey.ShouldEqual, trace.SpanKindClient)
+		attrs := spanAttributes(span)
+		convey.So(attrs[repoKey].AsString(), convey.ShouldEqual, testRepo)
+		convey.So(attrs[chartKey].AsString(), convey.ShouldEqual, "mysql")
+		convey.So(attrs[versionKey].AsString(), convey.ShouldEqual, "9.3.4")
+		convey.So(attrs[semconv.HTTPMethodKey].AsString(), convey.ShouldEqual, http.MethodGet)
+		convey.So(attrs[semconv.HTTPStatusCodeKey].AsInt64(), convey.ShouldEqual, http.StatusOK)
+		convey.So(attrs[retryAttemptKey].AsInt64(), convey.ShouldEqual, 1)
+		convey.So(span.Events, convey.ShouldHaveLength, 1)
+
+		span = spans[1]
+		attrs = spanAttributes(span)
+		convey.So(attrs[semconv.HTTPStatusCodeKey].AsInt64(), convey.ShouldEqual, http.StatusNotFound)
+		convey.So(attrs[retryAttemptKey].AsInt64(), convey.ShouldEqual, 0)
+		convey.So(span.Status.Code, convey.ShouldEqual, codes.Error)
+
+		// The trace context of the last span is propagated with W3C trace context.
+		lock.Lock()
+		lastTraceparent := traceparent
+		lock.Unlock()
+		convey.So(lastTraceparent, convey.ShouldEqual, "00-"+span.SpanContext.TraceID().String()+"-"+span.SpanContext.SpanID().String()+"-01")
+	})
+}
+
+func TestClient_OperationOptions(t *testing.T) {
+	convey.Convey("不修改调用方传入的 options", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"healthy":true}`))
+		}))
+		defer ts.Close()
+		client, _ := NewClient(WithBaseURL(ts.URL))
+
+		options := make([]RequestOptionFunc, 1, 2)
+		options[0] = WithUpload("application/json", -1)
+		_, err := client.Info.Health(options...)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(options[:2][1], convey.ShouldBeNil)
+	})
+}